@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+	"ydb-sample/internal/query"
+	"ydb-sample/internal/topic"
+)
+
+const issuesChangefeed = "issues/updates"
+
+// runChangefeedCommand implements
+//
+//	changefeed follow [-from 2006-01-02T15:04:05Z | -earliest]
+//	changefeed replay [-topic issues/updates] [-from time] [-to time]
+//	changefeed reset  -consumer name [-topic issues/updates] [-from time]
+//
+// follow runs the changefeed worker until interrupted, replay logs a time
+// window without touching any consumer, and reset moves the committed
+// offsets of a stopped consumer to -from or, without it, to the earliest
+// retained record.
+func runChangefeedCommand(ctx context.Context, queryHelper *query.QueryHelper, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: changefeed follow|replay|reset [flags]")
+	}
+
+	var flags = flag.NewFlagSet("changefeed "+args[0], flag.ContinueOnError)
+	var path = flags.String("topic", issuesChangefeed, "changefeed topic")
+	var consumer = flags.String("consumer", "", "consumer whose offsets reset moves")
+	var from = flags.String("from", "", "RFC 3339 time of the first record")
+	var to = flags.String("to", "", "RFC 3339 time after the last record replayed")
+	var earliest = flags.Bool("earliest", false, "follow from the earliest retained record")
+	var err = flags.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+
+	fromTime, err := parseTime(*from)
+	if err != nil {
+		return err
+	}
+	toTime, err := parseTime(*to)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "follow":
+		var opts []topic.ConsumerOption
+		switch {
+		case !fromTime.IsZero():
+			opts = append(opts, topic.WithStartFrom(fromTime))
+		case *earliest:
+			opts = append(opts, topic.WithStartFromEarliest())
+		}
+
+		worker, err := topic.NewReaderChangefeedWorker(queryHelper.Topic(), opts...)
+		if err != nil {
+			return err
+		}
+		worker.Run(ctx)
+
+		<-ctx.Done()
+		shutdown("reader changefeed worker", worker.Shutdown)
+		return nil
+	case "replay":
+		handled, err := topic.Replay(
+			ctx,
+			queryHelper.Topic(),
+			*path,
+			fromTime,
+			toTime,
+			topic.HandlerFunc(topic.LogIssueChange),
+		)
+		log.Printf("Replayed %d records\n", handled)
+		return err
+	case "reset":
+		if *consumer == "" {
+			return errors.New("changefeed reset requires -consumer")
+		}
+
+		offsets, err := topic.ResetOffsets(ctx, queryHelper, *path, *consumer, fromTime)
+		for _, offset := range offsets {
+			log.Printf("%s: partition %d committed at %d\n", *consumer, offset.PartitionId, offset.Offset)
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown changefeed command %q", args[0])
+	}
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
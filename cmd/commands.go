@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"ydb-sample/internal/query"
+)
+
+// runCommand dispatches the subcommands given after the global flags.
+// Without a subcommand main runs the end-to-end demo instead.
+func runCommand(ctx context.Context, queryHelper *query.QueryHelper, args []string) error {
+	switch args[0] {
+	case "dlq":
+		return runDeadLetterCommand(ctx, queryHelper, args[1:])
+	case "history":
+		return runHistoryCommand(ctx, queryHelper, args[1:])
+	case "changefeed":
+		return runChangefeedCommand(ctx, queryHelper, args[1:])
+	case "links":
+		return runLinksCommand(ctx, queryHelper, args[1:])
+	case "graph":
+		return runGraphCommand(ctx, queryHelper, args[1:])
+	case "stats":
+		return runStatsCommand(ctx, queryHelper, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"ydb-sample/internal/query"
+	"ydb-sample/internal/topic"
+)
+
+// runDeadLetterCommand implements
+//
+//	dlq list    [-topic task_status_dlq] [-limit 100]
+//	dlq redrive [-topic task_status_dlq] [-limit 100]
+func runDeadLetterCommand(ctx context.Context, queryHelper *query.QueryHelper, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: dlq list|redrive [-topic path] [-limit n]")
+	}
+
+	var flags = flag.NewFlagSet("dlq "+args[0], flag.ContinueOnError)
+	var path = flags.String("topic", topic.DeadLetterTopic, "dead-letter topic")
+	var limit = flags.Int("limit", 100, "maximum number of messages")
+	var err = flags.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		records, err := topic.InspectDeadLetters(ctx, queryHelper.Topic(), *path, *limit)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			log.Printf(
+				"offset %d from %s/%s@%s failed at %s: %s\n\tdata: %s\n",
+				record.Offset,
+				record.SourceTopic,
+				record.SourcePartition,
+				record.SourceOffset,
+				record.FailedAt,
+				record.Error,
+				record.Data,
+			)
+		}
+		log.Printf("%d dead letters\n", len(records))
+		return nil
+	case "redrive":
+		redriven, err := topic.RedriveDeadLetters(ctx, queryHelper.Topic(), *path, taskStatusProducers, *limit)
+		log.Printf("Re-driven %d dead letters\n", redriven)
+		return err
+	default:
+		return fmt.Errorf("unknown dlq command %q", args[0])
+	}
+}
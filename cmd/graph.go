@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"ydb-sample/internal/issue"
+	"ydb-sample/internal/query"
+
+	"github.com/google/uuid"
+)
+
+// runGraphCommand implements
+//
+//	graph show [-depth 2] [-kind blocks,child-of] [-format dot|mermaid] <issue-id>
+//	graph path [-depth 6] [-kind ...] <from-id> <to-id>
+//	graph components [-kind ...]
+//	graph cycles [-kind blocks]
+//
+// show prints the subgraph to stdout so it can be piped into dot or
+// pasted into a Mermaid block.
+func runGraphCommand(ctx context.Context, queryHelper *query.QueryHelper, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: graph show|path|components|cycles [flags] [ids]")
+	}
+
+	var flags = flag.NewFlagSet("graph "+args[0], flag.ContinueOnError)
+	var depth = flags.Int("depth", 0, "maximum number of hops (show: 2, path: 6)")
+	var kindList = flags.String("kind", "", "comma-separated link kinds to follow")
+	var format = flags.String("format", "dot", "output format of show: dot or mermaid")
+	var err = flags.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+
+	kinds, err := parseLinkKinds(*kindList)
+	if err != nil {
+		return err
+	}
+	ids, err := parseIds(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	var issuesRepository = issue.NewIssueRepository(queryHelper)
+
+	switch args[0] {
+	case "show":
+		if len(ids) != 1 {
+			return errors.New("usage: graph show [flags] <issue-id>")
+		}
+
+		graph, err := issuesRepository.Traverse(ctx, ids[0], orDefault(*depth, 2), kinds...)
+		if err != nil {
+			return err
+		}
+
+		switch *format {
+		case "dot":
+			_, err = fmt.Fprint(os.Stdout, graph.DOT())
+		case "mermaid":
+			_, err = fmt.Fprint(os.Stdout, graph.Mermaid())
+		default:
+			err = fmt.Errorf("unknown format %q", *format)
+		}
+		return err
+	case "path":
+		if len(ids) != 2 {
+			return errors.New("usage: graph path [flags] <from-id> <to-id>")
+		}
+
+		path, err := issuesRepository.ShortestPath(ctx, ids[0], ids[1], orDefault(*depth, 6), kinds...)
+		if err != nil {
+			return err
+		}
+		log.Printf("%d hops: %v\n", len(path)-1, path)
+		return nil
+	case "components":
+		components, err := issuesRepository.ConnectedComponents(ctx, kinds...)
+		if err != nil {
+			return err
+		}
+		for i, component := range components.Groups {
+			log.Printf("component %d (%d issues): %v\n", i+1, len(component), component)
+		}
+		log.Printf("%d unlinked issues\n", components.Unlinked)
+		return nil
+	case "cycles":
+		var kind = issue.LinkBlocks
+		if len(kinds) > 1 {
+			return errors.New("graph cycles takes a single -kind")
+		}
+		if len(kinds) == 1 {
+			kind = kinds[0]
+		}
+
+		cycles, err := issuesRepository.FindCycles(ctx, kind)
+		if err != nil {
+			return err
+		}
+		for _, cycle := range cycles {
+			log.Printf("%s cycle: %v\n", kind, cycle)
+		}
+		log.Printf("%d cycles\n", len(cycles))
+		return nil
+	default:
+		return fmt.Errorf("unknown graph command %q", args[0])
+	}
+}
+
+func parseLinkKinds(value string) ([]issue.LinkKind, error) {
+	var kinds = make([]issue.LinkKind, 0)
+	if value == "" {
+		return kinds, nil
+	}
+
+	for _, name := range strings.Split(value, ",") {
+		kind, err := issue.ParseLinkKind(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+func parseIds(values []string) ([]uuid.UUID, error) {
+	var ids = make([]uuid.UUID, 0, len(values))
+	for _, value := range values {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func orDefault(value int, fallback int) int {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"ydb-sample/internal/issue"
+	"ydb-sample/internal/query"
+
+	"github.com/google/uuid"
+)
+
+// runHistoryCommand implements
+//
+//	history <issue-id>
+func runHistoryCommand(ctx context.Context, queryHelper *query.QueryHelper, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: history <issue-id>")
+	}
+
+	id, err := uuid.Parse(args[0])
+	if err != nil {
+		return err
+	}
+
+	return printHistory(ctx, issue.NewIssueRepository(queryHelper), id)
+}
+
+// printHistory logs one line per change, listing the columns it modified.
+func printHistory(ctx context.Context, issuesRepository *issue.IssueRepository, id uuid.UUID) error {
+	entries, err := issuesRepository.History(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("History of %s:\n", id)
+	for i, entry := range entries {
+		if i == 0 || entry.Step != entries[i-1].Step || entry.TxId != entries[i-1].TxId {
+			log.Printf("%d:%d %s\n", entry.Step, entry.TxId, entry.Kind)
+		}
+		log.Printf("\t%s: %q -> %q\n", entry.Column, entry.OldValue, entry.NewValue)
+	}
+	log.Printf("%d changed columns\n", len(entries))
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"ydb-sample/internal/issue"
+	"ydb-sample/internal/query"
+)
+
+// runLinksCommand implements
+//
+//	links verify [-fix] [-batch 100]
+//
+// verify reports link counters that differ from the links table and links
+// pointing at deleted issues; with -fix it repairs them and verifies again.
+func runLinksCommand(ctx context.Context, queryHelper *query.QueryHelper, args []string) error {
+	if len(args) == 0 || args[0] != "verify" {
+		return errors.New("usage: links verify [-fix] [-batch n]")
+	}
+
+	var flags = flag.NewFlagSet("links verify", flag.ContinueOnError)
+	var fix = flags.Bool("fix", false, "repair the reported problems")
+	var batchSize = flags.Int("batch", 100, "links or issues per repair transaction")
+	var err = flags.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+
+	var issuesRepository = issue.NewIssueRepository(queryHelper)
+
+	report, err := issuesRepository.VerifyLinks(ctx)
+	if err != nil {
+		return err
+	}
+	printLinkReport(report)
+
+	if report.Consistent() {
+		return nil
+	}
+	if !*fix {
+		return fmt.Errorf("%d drifted counters, %d dangling links", len(report.Drift), len(report.Dangling))
+	}
+
+	err = issuesRepository.RepairLinks(ctx, report, *batchSize)
+	if err != nil {
+		return err
+	}
+
+	report, err = issuesRepository.VerifyLinks(ctx)
+	if err != nil {
+		return err
+	}
+	if !report.Consistent() {
+		printLinkReport(report)
+		return errors.New("links are still inconsistent after repair")
+	}
+	log.Println("Links repaired")
+
+	return nil
+}
+
+func printLinkReport(report *issue.LinkReport) {
+	for _, edge := range report.Dangling {
+		log.Printf("dangling %s link %s -> %s\n", edge.Kind, edge.Source, edge.Destination)
+	}
+	for _, drift := range report.Drift {
+		var counter = "links_count"
+		if drift.Kind != "" {
+			counter = "link_counts[" + drift.Kind + "]"
+		}
+		log.Printf("%s of %s: stored %d, actual %d\n", counter, drift.IssueId, drift.Stored, drift.Actual)
+	}
+	log.Printf("%d drifted counters, %d dangling links\n", len(report.Drift), len(report.Dangling))
+}
@@ -3,47 +3,90 @@ package main
 import (
 	"context"
 	"encoding/csv"
+	"errors"
+	"flag"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 	"ydb-sample/internal/bulk"
 	"ydb-sample/internal/issue"
+	"ydb-sample/internal/notification"
+	"ydb-sample/internal/outbox"
+	"ydb-sample/internal/projection"
 	"ydb-sample/internal/query"
 	"ydb-sample/internal/schema"
+	"ydb-sample/internal/statusevent"
 	"ydb-sample/internal/topic"
 
 	"github.com/google/uuid"
 )
 
+// sampleActor is recorded as the author of every status change made here.
+const sampleActor = "ydb-sample"
+
+const shutdownTimeout = 10 * time.Second
+
+// taskStatusProducers matches max_active_partitions of task_status.
+const taskStatusProducers = 10
+
 func main() {
-	var ctx, cancel = context.WithCancel(context.Background())
-	defer cancel()
+	var configPath = flag.String("config", os.Getenv("YDB_SAMPLE_CONFIG"), "path to YAML config file")
+	var eventEncoding = flag.String("event-encoding", "json", "task_status payload encoding: json or protobuf")
+	flag.Parse()
+
+	encoding, err := statusevent.ParseEncoding(*eventEncoding)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	var queryHelper = query.NewQueryHelper(ctx, "grpc://localhost:2136/local")
-	defer queryHelper.Close()
+	var ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	config, err := query.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	queryHelper, err := query.NewQueryHelper(ctx, config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// ctx is already cancelled after a signal; the driver gets its own.
+	defer shutdown("driver", queryHelper.Close)
+
+	if flag.NArg() > 0 {
+		err = runCommand(ctx, queryHelper, flag.Args())
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	var schemaRepository = schema.NewSchemaRepository(queryHelper)
 	var issuesRepository = issue.NewIssueRepository(queryHelper)
 
 	log.Println("Creating schema...")
 
-	schemaRepository.DropSchema()
-	schemaRepository.CreateSchema()
-	schemaRepository.CreateAuthorIndex()
+	schemaRepository.DropSchema(ctx)
+	schemaRepository.CreateSchema(ctx)
+	schemaRepository.CreateAuthorIndex(ctx)
 
 	// ====== TEST INSERT DATA ======
 	log.Println("Inserting data...")
 
-	firstIssue, err := issuesRepository.AddIssue("Ticket 1", "Author 1")
+	firstIssue, err := issuesRepository.AddIssue(ctx, "Ticket 1", "Author 1")
 	if err != nil {
 		log.Fatalf("Some error happened (1): %v\n", err)
 	}
 
-	secondIssue, err := issuesRepository.AddIssue("Ticket 2", "Author 2")
+	secondIssue, err := issuesRepository.AddIssue(ctx, "Ticket 2", "Author 2")
 	if err != nil {
 		log.Fatalf("Some error happened (2): %v\n", err)
 	}
 
-	thirdIssue, err := issuesRepository.AddIssue("Ticket 3", "Author 3")
+	thirdIssue, err := issuesRepository.AddIssue(ctx, "Ticket 3", "Author 3")
 	if err != nil {
 		log.Fatalf("Some error happened (3): %v\n", err)
 	}
@@ -51,7 +94,7 @@ func main() {
 	// ====== TEST DATA ======
 	log.Println("Checking data...")
 
-	allIssues, err := issuesRepository.FindAll()
+	allIssues, err := issuesRepository.FindAll(ctx)
 	if err != nil {
 		log.Fatalf("Some error happened while find all: %v\n", err)
 	}
@@ -61,19 +104,19 @@ func main() {
 		log.Printf("%v\n", issue)
 	}
 
-	first, err := issuesRepository.FindById(firstIssue.Id)
+	first, err := issuesRepository.FindById(ctx, firstIssue.Id)
 	if err != nil {
 		log.Fatal(err)
 	}
 	log.Printf("First: %v\n", first)
 
-	second, err := issuesRepository.FindById(secondIssue.Id)
+	second, err := issuesRepository.FindById(ctx, secondIssue.Id)
 	if err != nil {
 		log.Fatal(err)
 	}
 	log.Printf("Second: %v\n", second)
 
-	third, err := issuesRepository.FindById(thirdIssue.Id)
+	third, err := issuesRepository.FindById(ctx, thirdIssue.Id)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -82,22 +125,73 @@ func main() {
 	// ====== TEST TRANSACTIONS ======
 	log.Println("Checking non-interactive transaction...")
 
-	result1, err := issuesRepository.LinkTicketsNoInteractive(first.Id, second.Id)
+	result1, err := issuesRepository.LinkTicketsNoInteractive(ctx, first.Id, second.Id)
 	if err != nil {
 		log.Fatal(err)
 	}
 	log.Printf("Non-interactive transaction result: %v\n", result1)
 
-	result2, err := issuesRepository.LinkTicketsInteractive(second.Id, third.Id)
+	result2, err := issuesRepository.LinkTicketsInteractive(ctx, second.Id, third.Id)
 	if err != nil {
 		log.Fatal(err)
 	}
 	log.Printf("Interactive transaction result: %v\n", result2)
 
+	log.Println("Linking the same tickets again...")
+
+	result3, err := issuesRepository.LinkTicketsInteractive(ctx, first.Id, second.Id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Repeated link result: %v\n", result3)
+
+	linked, err := issuesRepository.FindLinked(ctx, second.Id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Linked to second: %v\n", linked)
+
+	result4, err := issuesRepository.UnlinkTickets(ctx, second.Id, third.Id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Unlink result: %v\n", result4)
+
+	log.Println("Checking typed links...")
+
+	_, err = issuesRepository.Link(ctx, first.Id, issue.LinkBlocks, third.Id)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	blockedBy, err := issuesRepository.FindLinkedByKind(ctx, third.Id, issue.LinkBlockedBy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Third is blocked by: %v\n", blockedBy)
+
+	linkCounts, err := issuesRepository.LinkCounts(ctx, first.Id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("First link counts: %v\n", linkCounts)
+
+	graph, err := issuesRepository.Traverse(ctx, first.Id, 2)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Links around first:\n%s", graph.Mermaid())
+
+	cycles, err := issuesRepository.FindCycles(ctx, issue.LinkBlocks)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Blocking cycles: %v\n", cycles)
+
 	// ====== TEST DATA AGAIN ======
 	log.Println("All issues:")
 
-	allIssues, err = issuesRepository.FindAll()
+	allIssues, err = issuesRepository.FindAll(ctx)
 	if err != nil {
 		log.Fatalf("Some error happened while find all: %v\n", err)
 	}
@@ -109,59 +203,130 @@ func main() {
 	// ====== TEST AUTHOR INDEX ======
 	log.Println("Find by index 'authorIndex':")
 
-	author2Issues, err := issuesRepository.FindByAuthor("Author 2")
+	author2Issues, err := issuesRepository.FindByAuthor(ctx, "Author 2")
 	if err != nil {
 		log.Fatal(err)
 	}
 	log.Printf("Author 2 issues: %v", author2Issues)
 
+	// ====== TEST PAGINATION ======
+	log.Println("Issues page by page:")
+
+	var cursor issue.Cursor
+	for {
+		page, err := issuesRepository.FindPage(ctx, cursor, issue.WithPageSize(2))
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Page of %d issues\n", len(page.Issues))
+
+		if page.Next == "" {
+			break
+		}
+		cursor = page.Next
+	}
+
+	for streamed, err := range issuesRepository.StreamByAuthor(ctx, "Author 2") {
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Streamed: %s\n", streamed.Title)
+	}
+
 	// ====== TEST TOPICS ======
-	updateService, err := topic.NewStatusUpdateService(
+	var outboxRepository = outbox.NewOutboxRepository(queryHelper)
+	var updateService = topic.NewStatusUpdateService(
+		queryHelper,
 		issuesRepository,
-		queryHelper.Topic(),
+		outboxRepository,
+		encoding,
 	)
+
+	outboxRelay, err := topic.NewOutboxRelay(outboxRepository, queryHelper.Topic(), taskStatusProducers)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	outboxRelay.Run(ctx)
+
 	log.Println("Update status for all tickets: NULL -> OPEN")
-	for _, issue := range allIssues {
-		var err = updateService.Update(ctx, issue.Id, "OPEN")
+	for _, item := range allIssues {
+		var err = updateService.Update(ctx, item.Id, issue.StatusOpen, sampleActor)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
 
-	readerWorker, err := topic.NewReaderWorker(queryHelper.Topic())
+	deadLetterWriter, err := topic.NewDeadLetterWriter(queryHelper.Topic(), topic.DeadLetterTopic)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var readerMetrics topic.ConsumerMetrics
+	readerWorker, err := topic.NewReaderWorker(
+		queryHelper.Topic(),
+		queryHelper,
+		topic.WithMiddleware(
+			topic.DeadLetter(deadLetterWriter),
+			topic.Logging("reader worker", false),
+			topic.Metrics(&readerMetrics),
+			topic.Retry(3),
+		),
+		topic.WithCommitPolicy(topic.CommitPeriodically(time.Second)),
+		topic.WithPartitionConcurrency(),
+	)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	readerWorker.Run(ctx)
 
+	historyWorker, err := topic.NewStatusHistoryWorker(queryHelper)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	historyWorker.Run(ctx)
+
 	log.Println("Update status for all tickets: NULL -> IN_PROGRESS")
-	for _, issue := range allIssues {
-		var err = updateService.Update(ctx, issue.Id, "IN_PROGRESS")
+	for _, item := range allIssues {
+		var err = updateService.Update(ctx, item.Id, issue.StatusInProgress, sampleActor)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
 
-	err = updateService.Shutdown(ctx)
-	log.Println("Shutdown update service...")
+	shutdown("outbox relay", outboxRelay.Shutdown)
+	shutdown("reader worker", readerWorker.Shutdown)
+	shutdown("status history worker", historyWorker.Shutdown)
+	shutdown("dead-letter writer", deadLetterWriter.Close)
+	log.Printf(
+		"Reader worker exited: %v (handled %d, failed %d)\n",
+		readerWorker.Err(),
+		readerMetrics.Handled.Load(),
+		readerMetrics.Failed.Load(),
+	)
+
+	notifications, err := notification.NewNotificationRepository(queryHelper).FindAll(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
+	log.Printf("Queued %d e-mail notifications\n", len(notifications))
 
-	err = readerWorker.Shutdown(ctx)
-	log.Println("Shutdown reader worker...")
-	if err != nil {
-		log.Fatal(err)
+	if len(allIssues) > 0 {
+		log.Printf("Status history of %s:\n", allIssues[0].Id)
+		history, err := issue.NewStatusHistoryRepository(queryHelper).FindByIssue(ctx, allIssues[0].Id)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, entry := range history {
+			log.Printf("%s: %q -> %q by %s\n", entry.ChangedAt, entry.OldStatus, entry.NewStatus, entry.Actor)
+		}
 	}
 
 	log.Println("Print all issues")
 
-	allIssues, err = issuesRepository.FindAll()
+	allIssues, err = issuesRepository.FindAll(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -177,28 +342,99 @@ func main() {
 		log.Fatal(err)
 	}
 
-	readerChangefeedWorker.ReadChangefeed(ctx)
+	readerChangefeedWorker.Run(ctx)
+
+	historyProjector, err := topic.NewIssueHistoryProjector(queryHelper, issuesRepository)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	historyProjector.Run(ctx)
+
+	var statsRepository = projection.NewStatsRepository(queryHelper)
+	statsProjector, err := topic.NewStatsProjector(queryHelper, statsRepository)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	statsProjector.Run(ctx)
+
+	err = updateService.Update(ctx, first.Id, issue.StatusFuture, sampleActor)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = issuesRepository.SoftDelete(ctx, second.Id)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = issuesRepository.FindById(ctx, second.Id)
+	if !errors.Is(err, issue.ErrIssueNotFound) {
+		log.Fatalf("Trashed issue is still visible: %v\n", err)
+	}
+
+	trashed, err := issuesRepository.FindById(ctx, second.Id, issue.IncludeDeleted())
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Trashed issue %s at %v\n", trashed.Id, trashed.DeletedAt)
+
+	err = issuesRepository.Restore(ctx, second.Id)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = issuesRepository.Delete(ctx, second.Id, issue.DeleteRestrict)
+	if !errors.Is(err, issue.ErrHasLinks) {
+		log.Fatalf("Restricted delete of a linked issue: %v\n", err)
+	}
+	log.Printf("Restricted delete refused: %v\n", err)
 
-	err = issuesRepository.UpdateStatus(first.Id, "FUTURE")
+	err = issuesRepository.Delete(ctx, second.Id, issue.DeleteCascade)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = issuesRepository.Delete(second.Id)
+	linkReport, err := issuesRepository.VerifyLinks(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
+	log.Printf(
+		"After delete: %d drifted link counters, %d dangling links\n",
+		len(linkReport.Drift),
+		len(linkReport.Dangling),
+	)
 
-	err = issuesRepository.Delete(second.Id)
+	err = issuesRepository.RepairLinks(ctx, linkReport, 100)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	readerChangefeedWorker.Shutdown(ctx)
+	shutdown("reader changefeed worker", readerChangefeedWorker.Shutdown)
+	shutdown("issue history projector", historyProjector.Shutdown)
+	shutdown("stats projector", statsProjector.Shutdown)
+	log.Printf("Reader changefeed worker exited: %v\n", readerChangefeedWorker.Err())
+
+	err = printHistory(ctx, issuesRepository, first.Id)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = printStats(ctx, statsRepository)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mismatches, err := projection.Check(ctx, statsRepository)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Projections differ from issues in %d rows\n", len(mismatches))
 
 	log.Println("Print all issues")
 
-	allIssues, err = issuesRepository.FindAll()
+	allIssues, err = issuesRepository.FindAll(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -210,7 +446,7 @@ func main() {
 	// ====== TEST COMPLEX QUERIES ======
 	log.Println("Testing complex queries...")
 
-	err = issuesRepository.AddIssues([]string{
+	err = issuesRepository.AddIssues(ctx, []string{
 		"Ticket 4",
 		"Ticket 5",
 		"Ticket 6",
@@ -221,7 +457,7 @@ func main() {
 
 	log.Println("Print all issues")
 
-	allIssues, err = issuesRepository.FindAll()
+	allIssues, err = issuesRepository.FindAll(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -232,8 +468,8 @@ func main() {
 
 	log.Println("Update all issues' status")
 
-	for _, issue := range allIssues {
-		err = issuesRepository.UpdateStatus(issue.Id, "FUTURE")
+	for _, item := range allIssues {
+		err = updateService.Update(ctx, item.Id, issue.StatusFuture, sampleActor)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -241,7 +477,7 @@ func main() {
 
 	log.Println("Find by ids:")
 
-	foundByIds, err := issuesRepository.FindByIds([]uuid.UUID{
+	foundByIds, err := issuesRepository.FindByIds(ctx, []uuid.UUID{
 		third.Id,
 		allIssues[4].Id,
 	})
@@ -255,7 +491,7 @@ func main() {
 
 	log.Println("Future issues:")
 
-	futureIssues, err := issuesRepository.FindFutures()
+	futureIssues, err := issuesRepository.FindFutures(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -264,20 +500,31 @@ func main() {
 		log.Printf("%v\n", issue)
 	}
 
+	log.Println("Promote future issues: FUTURE -> NEW")
+
+	promotedIssues, err := updateService.PromoteFutures(ctx, sampleActor)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, issue := range promotedIssues {
+		log.Printf("%v\n", issue)
+	}
+
 	log.Println("Delete issues by id")
 
-	err = issuesRepository.DeleteByIds([]uuid.UUID{
+	err = issuesRepository.DeleteByIds(ctx, []uuid.UUID{
 		first.Id,
 		allIssues[3].Id,
 		secondIssue.Id,
-	})
+	}, issue.DeleteCascade)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	log.Println("Print all issues")
 
-	allIssues, err = issuesRepository.FindAll()
+	allIssues, err = issuesRepository.FindAll(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -289,8 +536,9 @@ func main() {
 	// ====== TEST BULK OPERATIONS ======
 	keyValueApiRepository := bulk.NewKeyValueApiRepository(queryHelper)
 
-	log.Println("Dropping author index...")
-	schemaRepository.DropAuthorIndex()
+	log.Println("Dropping author and created_at indexes...")
+	schemaRepository.DropAuthorIndex(ctx)
+	schemaRepository.DropCreatedAtIndex(ctx)
 
 	log.Println("Reading CSV file...")
 	titleAuthorSlice, err := readTitleAuthorCSV("title_author.csv")
@@ -300,14 +548,14 @@ func main() {
 
 	log.Println("Perform bulk upsert")
 
-	err = keyValueApiRepository.BulkUpsert("/local/issues", titleAuthorSlice)
+	err = keyValueApiRepository.BulkUpsert(ctx, "/local/issues", titleAuthorSlice)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	log.Println("Print all issues")
 
-	allIssues, err = issuesRepository.FindAll()
+	allIssues, err = issuesRepository.FindAll(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -320,7 +568,7 @@ func main() {
 
 	log.Println("Read table")
 
-	readTableIssues, err := keyValueApiRepository.ReadTable("/local/issues")
+	readTableIssues, err := keyValueApiRepository.ReadTable(ctx, "/local/issues")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -340,8 +588,22 @@ func main() {
 		log.Printf("%v\n", issue)
 	}
 
-	log.Println("Creating author index...")
-	schemaRepository.CreateAuthorIndex()
+	log.Println("Creating author and created_at indexes...")
+	schemaRepository.CreateAuthorIndex(ctx)
+	schemaRepository.CreateCreatedAtIndex(ctx)
+}
+
+// shutdown gives a worker shutdownTimeout to drain. The timeout does not
+// derive from the main context, so workers still drain after a signal.
+func shutdown(name string, shutdownFunc func(context.Context) error) {
+	var ctx, cancel = context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	log.Printf("Shutdown %s...\n", name)
+	var err = shutdownFunc(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
 }
 
 func readTitleAuthorCSV(filename string) ([]issue.TitleAuthor, error) {
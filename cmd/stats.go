@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+	"ydb-sample/internal/projection"
+	"ydb-sample/internal/query"
+	"ydb-sample/internal/schema"
+	"ydb-sample/internal/topic"
+)
+
+// runStatsCommand implements
+//
+//	stats show
+//	stats check
+//	stats rebuild [-timeout 1m]
+//
+// rebuild recreates the issues/projections changefeed with an initial scan,
+// empties the projections and projects every row again until they match
+// the issues table. Stop running stats projectors first.
+func runStatsCommand(ctx context.Context, queryHelper *query.QueryHelper, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: stats show|check|rebuild [-timeout d]")
+	}
+
+	var flags = flag.NewFlagSet("stats "+args[0], flag.ContinueOnError)
+	var timeout = flags.Duration("timeout", time.Minute, "how long rebuild waits for the projections to catch up")
+	var err = flags.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+
+	var statsRepository = projection.NewStatsRepository(queryHelper)
+
+	switch args[0] {
+	case "show":
+		return printStats(ctx, statsRepository)
+	case "check":
+		mismatches, err := projection.Check(ctx, statsRepository)
+		if err != nil {
+			return err
+		}
+		for _, mismatch := range mismatches {
+			log.Println(mismatch)
+		}
+		if len(mismatches) > 0 {
+			return fmt.Errorf("%d projection rows are inconsistent", len(mismatches))
+		}
+		log.Println("Projections are consistent")
+		return nil
+	case "rebuild":
+		return rebuildStats(ctx, queryHelper, statsRepository, *timeout)
+	default:
+		return fmt.Errorf("unknown stats command %q", args[0])
+	}
+}
+
+func printStats(ctx context.Context, statsRepository *projection.StatsRepository) error {
+	authors, err := statsRepository.AuthorStats(ctx)
+	if err != nil {
+		return err
+	}
+	for _, stats := range authors {
+		log.Printf(
+			"author %q: total %d, open %d, in progress %d\n",
+			stats.Author,
+			stats.Total,
+			stats.Open,
+			stats.InProgress,
+		)
+	}
+
+	statuses, err := statsRepository.StatusStats(ctx)
+	if err != nil {
+		return err
+	}
+	for _, stats := range statuses {
+		log.Printf("status %q: total %d\n", stats.Status, stats.Total)
+	}
+
+	return nil
+}
+
+func rebuildStats(
+	ctx context.Context,
+	queryHelper *query.QueryHelper,
+	statsRepository *projection.StatsRepository,
+	timeout time.Duration,
+) error {
+	var schemaRepository = schema.NewSchemaRepository(queryHelper)
+
+	log.Println("Recreating the projections changefeed...")
+	schemaRepository.DropProjectionsChangefeed(ctx)
+
+	var err = statsRepository.Reset(ctx)
+	if err != nil {
+		return err
+	}
+
+	schemaRepository.CreateProjectionsChangefeed(ctx)
+
+	projector, err := topic.NewStatsProjector(queryHelper, statsRepository)
+	if err != nil {
+		return err
+	}
+	projector.Run(ctx)
+	defer shutdown("stats projector", projector.Shutdown)
+
+	var deadline = time.Now().Add(timeout)
+	for {
+		mismatches, err := projection.Check(ctx, statsRepository)
+		if err != nil {
+			return err
+		}
+		if len(mismatches) == 0 {
+			log.Println("Projections rebuilt")
+			return printStats(ctx, statsRepository)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("projections still have %d inconsistent rows after %v", len(mismatches), timeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
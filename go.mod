@@ -2,18 +2,22 @@ module ydb-sample
 
 go 1.25.3
 
-require github.com/ydb-platform/ydb-go-sdk/v3 v3.117.1
+require (
+	github.com/google/uuid v1.6.0
+	github.com/ydb-platform/ydb-go-genproto v0.0.0-20250911135631-b3beddd517d9
+	github.com/ydb-platform/ydb-go-sdk/v3 v3.117.1
+	google.golang.org/protobuf v1.36.10
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/golang-jwt/jwt/v4 v4.5.2 // indirect
-	github.com/google/uuid v1.6.0 // indirect
 	github.com/jonboulle/clockwork v0.5.0 // indirect
-	github.com/ydb-platform/ydb-go-genproto v0.0.0-20250911135631-b3beddd517d9 // indirect
+	github.com/kr/pretty v0.3.1 // indirect
 	golang.org/x/net v0.46.0 // indirect
 	golang.org/x/sync v0.17.0 // indirect
 	golang.org/x/sys v0.37.0 // indirect
 	golang.org/x/text v0.30.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20251014184007-4626949a642f // indirect
 	google.golang.org/grpc v1.76.0 // indirect
-	google.golang.org/protobuf v1.36.10 // indirect
 )
@@ -27,6 +27,7 @@ func NewKeyValueApiRepository(query *query.QueryHelper) *KeyValueApiRepository {
 }
 
 func (repo *KeyValueApiRepository) BulkUpsert(
+	ctx context.Context,
 	tableName string,
 	titleAuthorList []issue.TitleAuthor,
 ) error {
@@ -43,15 +44,17 @@ func (repo *KeyValueApiRepository) BulkUpsert(
 	)
 
 	return repo.query.BulkUpsert(
+		ctx,
 		tableName,
 		table.BulkUpsertDataRows(types.ListValue(values...)),
 	)
 }
 
-func (repo *KeyValueApiRepository) ReadTable(table string) ([]issue.Issue, error) {
+func (repo *KeyValueApiRepository) ReadTable(ctx context.Context, table string) ([]issue.Issue, error) {
 	resultIssues := make([]issue.Issue, 0)
 
 	err := repo.query.ReadTable(
+		ctx,
 		table,
 		func(rs result.StreamResult, ctx context.Context) error {
 			for rs.NextResultSet(ctx) {
@@ -92,6 +95,7 @@ func (repo *KeyValueApiRepository) ReadRows(
 	resultIssues := make([]issue.Issue, 0)
 
 	result, err := repo.query.ReadRows(
+		ctx,
 		table,
 		types.ListValue(
 			types.StructValue(
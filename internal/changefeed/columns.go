@@ -0,0 +1,65 @@
+package changefeed
+
+import (
+	"strconv"
+	"time"
+	"ydb-sample/internal/issue"
+)
+
+// ColumnChange is one column of an issues row that a change modified.
+// Values are rendered as text; NULL and absent images are empty strings.
+type ColumnChange struct {
+	Column   string
+	OldValue string
+	NewValue string
+}
+
+type column struct {
+	name  string
+	value func(*issue.Issue) string
+}
+
+// columns lists the non-key columns of issues in table order.
+var columns = []column{
+	{"title", func(i *issue.Issue) string { return i.Title }},
+	{"created_at", func(i *issue.Issue) string { return formatTimestamp(i.Timestamp) }},
+	{"author", func(i *issue.Issue) string { return i.Author }},
+	{"links_count", func(i *issue.Issue) string { return strconv.FormatUint(i.LinksCount, 10) }},
+	{"status", func(i *issue.Issue) string { return string(i.Status) }},
+	{"status_changed_at", func(i *issue.Issue) string { return formatTimestamp(i.StatusChangedAt) }},
+	{"status_changed_by", func(i *issue.Issue) string { return i.StatusChangedBy }},
+	{"deleted_at", func(i *issue.Issue) string { return formatTimestamp(i.DeletedAt) }},
+}
+
+// ChangedColumns compares the old and new images. An insert lists every
+// column it set and a delete every column it cleared.
+func (c IssueChange) ChangedColumns() []ColumnChange {
+	var result = make([]ColumnChange, 0, len(columns))
+
+	for _, col := range columns {
+		var change = ColumnChange{
+			Column:   col.name,
+			OldValue: imageValue(c.OldImage, col),
+			NewValue: imageValue(c.NewImage, col),
+		}
+		if change.OldValue != change.NewValue {
+			result = append(result, change)
+		}
+	}
+
+	return result
+}
+
+func imageValue(image *issue.Issue, col column) string {
+	if image == nil {
+		return ""
+	}
+	return col.value(image)
+}
+
+func formatTimestamp(ts time.Time) string {
+	if ts.IsZero() {
+		return ""
+	}
+	return ts.UTC().Format(time.RFC3339Nano)
+}
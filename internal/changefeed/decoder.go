@@ -0,0 +1,153 @@
+package changefeed
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"ydb-sample/internal/issue"
+
+	"github.com/google/uuid"
+)
+
+var ErrMalformedRecord = errors.New("malformed changefeed record")
+
+// record mirrors the JSON written by a changefeed with FORMAT = 'JSON',
+// MODE = 'NEW_AND_OLD_IMAGES' and VIRTUAL_TIMESTAMPS = TRUE.
+type record struct {
+	Key      []json.RawMessage `json:"key"`
+	Update   *json.RawMessage  `json:"update"`
+	Erase    *json.RawMessage  `json:"erase"`
+	OldImage *issueImage       `json:"oldImage"`
+	NewImage *issueImage       `json:"newImage"`
+	Ts       []uint64          `json:"ts"`
+	Resolved []uint64          `json:"resolved"`
+}
+
+// issueImage holds the non-key columns of an issues row. Columns that are
+// NULL are written as JSON null.
+type issueImage struct {
+	Title           *string `json:"title"`
+	CreatedAt       *string `json:"created_at"`
+	Author          *string `json:"author"`
+	LinksCount      *uint64 `json:"links_count"`
+	Status          *string `json:"status"`
+	StatusChangedAt *string `json:"status_changed_at"`
+	StatusChangedBy *string `json:"status_changed_by"`
+	DeletedAt       *string `json:"deleted_at"`
+}
+
+// DecodeIssueChange parses one changefeed message of the issues table.
+func DecodeIssueChange(data []byte) (IssueChange, error) {
+	var change IssueChange
+
+	var r record
+	var err = json.Unmarshal(data, &r)
+	if err != nil {
+		return change, fmt.Errorf("%w: %w", ErrMalformedRecord, err)
+	}
+
+	if r.Resolved != nil {
+		change.Kind = KindResolved
+		change.VirtualTimestamp, err = virtualTimestamp(r.Resolved)
+		return change, err
+	}
+
+	if len(r.Key) != 1 {
+		return change, fmt.Errorf("%w: expected one key column, got %d", ErrMalformedRecord, len(r.Key))
+	}
+
+	var key string
+	err = json.Unmarshal(r.Key[0], &key)
+	if err != nil {
+		return change, fmt.Errorf("%w: key: %w", ErrMalformedRecord, err)
+	}
+	change.Key, err = uuid.Parse(key)
+	if err != nil {
+		return change, fmt.Errorf("%w: key: %w", ErrMalformedRecord, err)
+	}
+
+	change.VirtualTimestamp, err = virtualTimestamp(r.Ts)
+	if err != nil {
+		return change, err
+	}
+
+	switch {
+	case r.Erase != nil:
+		change.Kind = KindDelete
+	case r.Update != nil && r.OldImage == nil:
+		change.Kind = KindInsert
+	case r.Update != nil:
+		change.Kind = KindUpdate
+	default:
+		return change, fmt.Errorf("%w: neither update nor erase", ErrMalformedRecord)
+	}
+
+	change.OldImage, err = r.OldImage.toIssue(change.Key)
+	if err != nil {
+		return change, err
+	}
+	change.NewImage, err = r.NewImage.toIssue(change.Key)
+	if err != nil {
+		return change, err
+	}
+
+	return change, nil
+}
+
+func virtualTimestamp(values []uint64) (VirtualTimestamp, error) {
+	if len(values) != 2 {
+		return VirtualTimestamp{}, fmt.Errorf("%w: virtual timestamp %v", ErrMalformedRecord, values)
+	}
+	return VirtualTimestamp{Step: values[0], TxId: values[1]}, nil
+}
+
+func (image *issueImage) toIssue(id uuid.UUID) (*issue.Issue, error) {
+	if image == nil {
+		return nil, nil
+	}
+
+	var result = &issue.Issue{
+		Id:              id,
+		Title:           deref(image.Title),
+		Author:          deref(image.Author),
+		LinksCount:      deref(image.LinksCount),
+		Status:          issue.Status(deref(image.Status)),
+		StatusChangedBy: deref(image.StatusChangedBy),
+	}
+
+	var err error
+	result.Timestamp, err = parseTimestamp(image.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	result.StatusChangedAt, err = parseTimestamp(image.StatusChangedAt)
+	if err != nil {
+		return nil, err
+	}
+	result.DeletedAt, err = parseTimestamp(image.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func parseTimestamp(value *string) (time.Time, error) {
+	if value == nil {
+		return time.Time{}, nil
+	}
+	ts, err := time.Parse(time.RFC3339Nano, *value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: timestamp: %w", ErrMalformedRecord, err)
+	}
+	return ts, nil
+}
+
+func deref[T any](value *T) T {
+	var zero T
+	if value == nil {
+		return zero
+	}
+	return *value
+}
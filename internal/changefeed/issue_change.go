@@ -0,0 +1,61 @@
+package changefeed
+
+import (
+	"fmt"
+	"ydb-sample/internal/issue"
+
+	"github.com/google/uuid"
+)
+
+type Kind int
+
+const (
+	KindInsert Kind = iota
+	KindUpdate
+	KindDelete
+	// KindResolved is a heartbeat: every change up to VirtualTimestamp has
+	// already been delivered. Only VirtualTimestamp is set.
+	KindResolved
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindInsert:
+		return "insert"
+	case KindUpdate:
+		return "update"
+	case KindDelete:
+		return "delete"
+	case KindResolved:
+		return "resolved"
+	default:
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+}
+
+// VirtualTimestamp orders changes across the whole database: by plan step
+// first and by transaction id inside one step.
+type VirtualTimestamp struct {
+	Step uint64
+	TxId uint64
+}
+
+func (ts VirtualTimestamp) Less(other VirtualTimestamp) bool {
+	if ts.Step != other.Step {
+		return ts.Step < other.Step
+	}
+	return ts.TxId < other.TxId
+}
+
+func (ts VirtualTimestamp) String() string {
+	return fmt.Sprintf("%d:%d", ts.Step, ts.TxId)
+}
+
+// IssueChange is one record of the issues/updates changefeed.
+type IssueChange struct {
+	Key              uuid.UUID
+	Kind             Kind
+	OldImage         *issue.Issue
+	NewImage         *issue.Issue
+	VirtualTimestamp VirtualTimestamp
+}
@@ -0,0 +1,43 @@
+package changefeed
+
+import (
+	"context"
+	"io"
+	"iter"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicreader"
+)
+
+// Changes reads the changefeed through reader and yields decoded records.
+// A message is committed once the loop body returns for it, so breaking out
+// of the loop leaves the current record to be delivered again. Read errors
+// are yielded once and end the sequence; decode errors are yielded and the
+// undecodable message is committed and skipped.
+func Changes(ctx context.Context, reader *topicreader.Reader) iter.Seq2[IssueChange, error] {
+	return func(yield func(IssueChange, error) bool) {
+		for {
+			message, err := reader.ReadMessage(ctx)
+			if err != nil {
+				yield(IssueChange{}, err)
+				return
+			}
+
+			content, err := io.ReadAll(message)
+			if err != nil {
+				yield(IssueChange{}, err)
+				return
+			}
+
+			change, err := DecodeIssueChange(content)
+			if !yield(change, err) {
+				return
+			}
+
+			err = reader.Commit(message.Context(), message)
+			if err != nil {
+				yield(IssueChange{}, err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package dedup
+
+import (
+	"context"
+	"time"
+	"ydb-sample/internal/query"
+
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+	ydbQuery "github.com/ydb-platform/ydb-go-sdk/v3/query"
+)
+
+// ProcessedEventRepository remembers which events a consumer has already
+// applied. Both methods run inside the caller's transaction, so the record
+// commits or rolls back together with the handler's own writes.
+type ProcessedEventRepository struct{}
+
+func NewProcessedEventRepository() *ProcessedEventRepository {
+	return &ProcessedEventRepository{}
+}
+
+type processedEvent struct {
+	EventId string `sql:"event_id"`
+}
+
+func (repo *ProcessedEventRepository) IsProcessed(
+	ctx context.Context,
+	tx ydbQuery.TxActor,
+	consumer string,
+	eventId string,
+) (bool, error) {
+	rows, err := tx.QueryResultSet(
+		ctx,
+		`
+		DECLARE $consumer AS Text;
+		DECLARE $event_id AS Text;
+
+		SELECT event_id FROM processed_events
+		WHERE consumer = $consumer AND event_id = $event_id;
+		`,
+		ydbQuery.WithParameters(
+			ydb.ParamsBuilder().
+				Param("$consumer").Text(consumer).
+				Param("$event_id").Text(eventId).
+				Build(),
+		),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	var result = make([]processedEvent, 0)
+	err = query.Materialize(rows, ctx, &result)
+	if err != nil {
+		return false, err
+	}
+
+	return len(result) > 0, nil
+}
+
+func (repo *ProcessedEventRepository) MarkProcessed(
+	ctx context.Context,
+	tx ydbQuery.TxActor,
+	consumer string,
+	eventId string,
+) error {
+	return tx.Exec(
+		ctx,
+		`
+		DECLARE $consumer AS Text;
+		DECLARE $event_id AS Text;
+		DECLARE $processed_at AS Timestamp;
+
+		INSERT INTO processed_events (consumer, event_id, processed_at)
+		VALUES ($consumer, $event_id, $processed_at);
+		`,
+		ydbQuery.WithParameters(
+			ydb.ParamsBuilder().
+				Param("$consumer").Text(consumer).
+				Param("$event_id").Text(eventId).
+				Param("$processed_at").Timestamp(time.Now()).
+				Build(),
+		),
+	)
+}
@@ -0,0 +1,218 @@
+package issue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"ydb-sample/internal/query"
+
+	"github.com/google/uuid"
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+	ydbQuery "github.com/ydb-platform/ydb-go-sdk/v3/query"
+)
+
+// The tests below need a YDB with the sample schema, e.g. the container of
+// deployment/docker-compose.yml after one `make run`. They are skipped
+// unless YDB_INTEGRATION is set; YDB_DSN and the other YDB_* variables
+// select the database.
+
+func newTestRepository(t *testing.T) (*IssueRepository, *query.QueryHelper) {
+	t.Helper()
+	if os.Getenv("YDB_INTEGRATION") == "" {
+		t.Skip("YDB_INTEGRATION is not set")
+	}
+
+	config, err := query.LoadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	helper, err := query.NewQueryHelper(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = helper.Close(context.Background())
+	})
+
+	return NewIssueRepository(helper), helper
+}
+
+func addTestIssue(t *testing.T, repo *IssueRepository, title string) uuid.UUID {
+	t.Helper()
+	issue, err := repo.AddIssue(context.Background(), title, "delete-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = repo.Delete(context.Background(), issue.Id, DeleteCascade)
+	})
+	return issue.Id
+}
+
+func linkTestIssues(t *testing.T, repo *IssueRepository, id1 uuid.UUID, kind LinkKind, id2 uuid.UUID) {
+	t.Helper()
+	var _, err = repo.Link(context.Background(), id1, kind, id2)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// linkRows counts the rows of links in either direction that mention id.
+func linkRows(t *testing.T, helper *query.QueryHelper, id uuid.UUID) uint64 {
+	t.Helper()
+	var found = make([]countRow, 0)
+
+	var err = helper.Query(context.Background(), `
+		DECLARE $id AS Uuid;
+
+		SELECT COUNT(*) AS cnt
+		FROM links
+		WHERE source = $id OR destination = $id;
+		`,
+		ydbQuery.SnapshotReadOnlyTxControl(),
+		ydb.ParamsBuilder().
+			Param("$id").Uuid(id).
+			Build(),
+		func(rs ydbQuery.ResultSet, ctx context.Context) error {
+			return query.Materialize(rs, ctx, &found)
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return found[0].Count
+}
+
+func assertLinksCount(t *testing.T, repo *IssueRepository, id uuid.UUID, want uint64) {
+	t.Helper()
+	issue, err := repo.FindById(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue.LinksCount != want {
+		t.Errorf("links_count of %s = %d, want %d", id, issue.LinksCount, want)
+	}
+}
+
+func assertLinkCounts(t *testing.T, repo *IssueRepository, id uuid.UUID, want map[string]uint64) {
+	t.Helper()
+	counts, err := repo.LinkCounts(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got = make(map[string]uint64, len(counts))
+	for _, count := range counts {
+		got[count.Kind] = count.LinksCount
+	}
+	if len(got) != len(want) {
+		t.Errorf("link_counts of %s = %v, want %v", id, got, want)
+		return
+	}
+	for kind, count := range want {
+		if got[kind] != count {
+			t.Errorf("link_counts of %s = %v, want %v", id, got, want)
+			return
+		}
+	}
+}
+
+func assertDeleted(t *testing.T, repo *IssueRepository, id uuid.UUID) {
+	t.Helper()
+	var _, err = repo.FindById(context.Background(), id, IncludeDeleted())
+	if !errors.Is(err, ErrIssueNotFound) {
+		t.Errorf("FindById(%s) after delete: err = %v, want ErrIssueNotFound", id, err)
+	}
+}
+
+func TestDeleteRestrictKeepsLinkedIssue(t *testing.T) {
+	var repo, helper = newTestRepository(t)
+	var ctx = context.Background()
+
+	var blocker = addTestIssue(t, repo, "restrict blocker")
+	var blocked = addTestIssue(t, repo, "restrict blocked")
+	linkTestIssues(t, repo, blocker, LinkBlocks, blocked)
+
+	var err = repo.Delete(ctx, blocker, DeleteRestrict)
+	if !errors.Is(err, ErrHasLinks) {
+		t.Fatalf("Delete: err = %v, want ErrHasLinks", err)
+	}
+
+	assertLinksCount(t, repo, blocker, 1)
+	assertLinksCount(t, repo, blocked, 1)
+	assertLinkCounts(t, repo, blocker, map[string]uint64{string(LinkBlocks): 1})
+	assertLinkCounts(t, repo, blocked, map[string]uint64{string(LinkBlockedBy): 1})
+	if rows := linkRows(t, helper, blocker); rows != 2 {
+		t.Errorf("link rows of %s = %d, want 2", blocker, rows)
+	}
+}
+
+func TestDeleteCascadeRemovesLinks(t *testing.T) {
+	var repo, helper = newTestRepository(t)
+	var ctx = context.Background()
+
+	var deleted = addTestIssue(t, repo, "cascade deleted")
+	var blocked = addTestIssue(t, repo, "cascade blocked")
+	var related = addTestIssue(t, repo, "cascade related")
+	linkTestIssues(t, repo, deleted, LinkBlocks, blocked)
+	linkTestIssues(t, repo, related, LinkRelatesTo, deleted)
+	linkTestIssues(t, repo, blocked, LinkRelatesTo, related)
+
+	var err = repo.Delete(ctx, deleted, DeleteCascade)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	assertDeleted(t, repo, deleted)
+	if rows := linkRows(t, helper, deleted); rows != 0 {
+		t.Errorf("link rows of %s = %d, want 0", deleted, rows)
+	}
+	assertLinkCounts(t, repo, deleted, map[string]uint64{})
+
+	// Only the links to the deleted issue are taken off the neighbours.
+	assertLinksCount(t, repo, blocked, 1)
+	assertLinksCount(t, repo, related, 1)
+	assertLinkCounts(t, repo, blocked, map[string]uint64{string(LinkRelatesTo): 1})
+	assertLinkCounts(t, repo, related, map[string]uint64{string(LinkRelatesTo): 1})
+}
+
+func TestDeleteWithoutLinks(t *testing.T) {
+	var repo, _ = newTestRepository(t)
+	var ctx = context.Background()
+
+	for _, policy := range []DeletePolicy{DeleteRestrict, DeleteCascade} {
+		var id = addTestIssue(t, repo, "unlinked")
+
+		var err = repo.Delete(ctx, id, policy)
+		if err != nil {
+			t.Fatalf("Delete(policy %d): %v", policy, err)
+		}
+		assertDeleted(t, repo, id)
+	}
+}
+
+func TestDeleteAlreadyDeleted(t *testing.T) {
+	var repo, _ = newTestRepository(t)
+	var ctx = context.Background()
+
+	var id = addTestIssue(t, repo, "deleted twice")
+	var neighbour = addTestIssue(t, repo, "neighbour")
+	linkTestIssues(t, repo, id, LinkRelatesTo, neighbour)
+
+	var err = repo.Delete(ctx, id, DeleteCascade)
+	if err != nil {
+		t.Fatalf("first Delete: %v", err)
+	}
+
+	for _, policy := range []DeletePolicy{DeleteRestrict, DeleteCascade} {
+		err = repo.Delete(ctx, id, policy)
+		if err != nil {
+			t.Errorf("repeated Delete(policy %d): %v", policy, err)
+		}
+	}
+
+	assertDeleted(t, repo, id)
+	assertLinksCount(t, repo, neighbour, 0)
+	assertLinkCounts(t, repo, neighbour, map[string]uint64{})
+}
@@ -0,0 +1,79 @@
+package issue
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ydb-platform/ydb-go-genproto/protos/Ydb"
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+)
+
+var (
+	ErrIssueNotFound   = errors.New("issue not found")
+	ErrDuplicateId     = errors.New("multiple issues with the same id")
+	ErrConflict        = errors.New("conflicting modification")
+	ErrInvalidStatus   = errors.New("invalid issue status")
+	ErrInvalidLinkKind = errors.New("invalid link kind")
+	ErrNoPath          = errors.New("no path between issues")
+	ErrGraphTooLarge   = errors.New("link graph too large")
+	ErrHasLinks        = errors.New("issue has links")
+	ErrSelfLink        = errors.New("issue cannot be linked to itself")
+	ErrInvalidCursor   = errors.New("invalid page cursor")
+	ErrUnavailable     = errors.New("database unavailable")
+)
+
+// YDB reports "Conflict with existing key" for INSERT into an existing
+// primary key with this issue code.
+const constraintViolationIssueCode = 2012
+
+// classify maps YDB SDK errors to the package sentinels. The original error
+// stays in the chain, so ydb.Is* helpers keep working on the result.
+func classify(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var kind error
+	switch {
+	case isDomainError(err):
+		return err
+	case isUniqueViolation(err):
+		kind = ErrConflict
+	case ydb.IsOperationErrorTransactionLocksInvalidated(err):
+		kind = ErrConflict
+	case ydb.IsOperationError(err, Ydb.StatusIds_PRECONDITION_FAILED):
+		kind = ErrConflict
+	case ydb.IsOperationErrorOverloaded(err),
+		ydb.IsOperationErrorUnavailable(err),
+		ydb.IsTransportError(err):
+		kind = ErrUnavailable
+	default:
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return fmt.Errorf("%s: %w: %w", op, kind, err)
+}
+
+func isDomainError(err error) bool {
+	return errors.Is(err, ErrIssueNotFound) ||
+		errors.Is(err, ErrDuplicateId) ||
+		errors.Is(err, ErrConflict) ||
+		errors.Is(err, ErrInvalidStatus) ||
+		errors.Is(err, ErrInvalidLinkKind) ||
+		errors.Is(err, ErrNoPath) ||
+		errors.Is(err, ErrGraphTooLarge) ||
+		errors.Is(err, ErrHasLinks) ||
+		errors.Is(err, ErrSelfLink) ||
+		errors.Is(err, ErrInvalidCursor) ||
+		errors.Is(err, ErrUnavailable)
+}
+
+func isUniqueViolation(err error) bool {
+	var found = false
+	ydb.IterateByIssues(err, func(_ string, code Ydb.StatusIds_StatusCode, _ uint32) {
+		if code == constraintViolationIssueCode {
+			found = true
+		}
+	})
+	return found
+}
@@ -0,0 +1,508 @@
+package issue
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"ydb-sample/internal/query"
+	"ydb-sample/internal/utils"
+
+	"github.com/google/uuid"
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+	ydbQuery "github.com/ydb-platform/ydb-go-sdk/v3/query"
+	"github.com/ydb-platform/ydb-go-sdk/v3/table/types"
+)
+
+const (
+	// maxGraphNodes bounds every traversal, whatever depth was asked for.
+	maxGraphNodes = 1000
+	// maxGraphEdges bounds the links read by whole-graph queries.
+	maxGraphEdges = 10 * maxGraphNodes
+)
+
+type Edge struct {
+	Source      uuid.UUID `sql:"source"`
+	Destination uuid.UUID `sql:"destination"`
+	Kind        string    `sql:"kind"`
+}
+
+// Graph is a subgraph of the link graph. Edges holds both stored
+// directions of every link.
+type Graph struct {
+	Issues map[uuid.UUID]Issue
+	Edges  []Edge
+}
+
+// Links returns every link once, in the direction of its primary kind
+// (blocks, duplicates, parent-of); relates-to links are ordered by id.
+func (g *Graph) Links() []Edge {
+	var result = make([]Edge, 0, len(g.Edges)/2)
+	for _, edge := range g.Edges {
+		var kind = LinkKind(edge.Kind)
+		switch kind {
+		case LinkBlockedBy, LinkDuplicatedBy, LinkChildOf:
+			continue
+		case LinkRelatesTo:
+			if edge.Source.String() > edge.Destination.String() {
+				continue
+			}
+		}
+		result = append(result, edge)
+	}
+	return result
+}
+
+// Traverse walks the links breadth-first from start up to depth hops,
+// following only kinds when given. One query per level reads the links of
+// the whole frontier.
+func (repo *IssueRepository) Traverse(
+	ctx context.Context,
+	start uuid.UUID,
+	depth int,
+	kinds ...LinkKind,
+) (*Graph, error) {
+	var visited = map[uuid.UUID]bool{start: true}
+	var frontier = []uuid.UUID{start}
+	var edges = make([]Edge, 0)
+
+	for level := 0; level < depth && len(frontier) > 0; level++ {
+		found, err := repo.outgoingEdges(ctx, frontier, kinds)
+		if err != nil {
+			return nil, err
+		}
+
+		var next = make([]uuid.UUID, 0)
+		for _, edge := range found {
+			edges = append(edges, edge)
+			if !visited[edge.Destination] && len(visited) < maxGraphNodes {
+				visited[edge.Destination] = true
+				next = append(next, edge.Destination)
+			}
+		}
+		frontier = next
+	}
+
+	var ids = make([]uuid.UUID, 0, len(visited))
+	for id := range visited {
+		ids = append(ids, id)
+	}
+
+	issues, err := repo.FindByIds(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var graph = &Graph{
+		Issues: make(map[uuid.UUID]Issue, len(issues)),
+		Edges:  make([]Edge, 0, len(edges)),
+	}
+	for _, item := range issues {
+		graph.Issues[item.Id] = item
+	}
+	// Keep only edges inside the subgraph, in both directions.
+	for _, edge := range edges {
+		if visited[edge.Source] && visited[edge.Destination] {
+			graph.Edges = append(graph.Edges, edge, Edge{
+				Source:      edge.Destination,
+				Destination: edge.Source,
+				Kind:        string(LinkKind(edge.Kind).Inverse()),
+			})
+		}
+	}
+	graph.Edges = uniqueEdges(graph.Edges)
+
+	return graph, nil
+}
+
+// ShortestPath returns the issues on a shortest chain of links from one
+// issue to another, both included, searching at most maxDepth hops.
+func (repo *IssueRepository) ShortestPath(
+	ctx context.Context,
+	from uuid.UUID,
+	to uuid.UUID,
+	maxDepth int,
+	kinds ...LinkKind,
+) ([]uuid.UUID, error) {
+	var parents = map[uuid.UUID]uuid.UUID{from: from}
+	var frontier = []uuid.UUID{from}
+
+	for level := 0; level < maxDepth && len(frontier) > 0; level++ {
+		if _, ok := parents[to]; ok {
+			break
+		}
+
+		found, err := repo.outgoingEdges(ctx, frontier, kinds)
+		if err != nil {
+			return nil, err
+		}
+
+		var next = make([]uuid.UUID, 0)
+		for _, edge := range found {
+			if _, ok := parents[edge.Destination]; ok || len(parents) >= maxGraphNodes {
+				continue
+			}
+			parents[edge.Destination] = edge.Source
+			next = append(next, edge.Destination)
+		}
+		frontier = next
+	}
+
+	if _, ok := parents[to]; !ok {
+		return nil, fmt.Errorf("ShortestPath %s -> %s: %w", from, to, ErrNoPath)
+	}
+
+	var path = []uuid.UUID{to}
+	for current := to; current != from; {
+		current = parents[current]
+		path = append(path, current)
+	}
+	slices.Reverse(path)
+
+	return path, nil
+}
+
+// Components are the connected parts of the link graph.
+type Components struct {
+	// Groups are the linked issues per component, largest first.
+	Groups [][]uuid.UUID
+	// Unlinked counts the live issues without links of the followed kinds.
+	Unlinked uint64
+}
+
+// ConnectedComponents groups the linked issues by the links between them,
+// following only kinds when given; unlinked issues are only counted. The
+// links and the count are read in one snapshot. It reads every link, so it
+// returns ErrGraphTooLarge beyond maxGraphEdges links.
+func (repo *IssueRepository) ConnectedComponents(
+	ctx context.Context,
+	kinds ...LinkKind,
+) (*Components, error) {
+	edges, live, err := repo.linkedGraph(ctx, kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	var parent = make(map[uuid.UUID]uuid.UUID)
+	var find func(uuid.UUID) uuid.UUID
+	find = func(id uuid.UUID) uuid.UUID {
+		if parent[id] != id {
+			parent[id] = find(parent[id])
+		}
+		return parent[id]
+	}
+
+	for _, edge := range edges {
+		for _, id := range []uuid.UUID{edge.Source, edge.Destination} {
+			if _, ok := parent[id]; !ok {
+				parent[id] = id
+			}
+		}
+		parent[find(edge.Source)] = find(edge.Destination)
+	}
+
+	var groups = make(map[uuid.UUID][]uuid.UUID)
+	for id := range parent {
+		var root = find(id)
+		groups[root] = append(groups[root], id)
+	}
+
+	// Largest components first, each sorted, so the output is stable.
+	var result = &Components{Groups: make([][]uuid.UUID, 0, len(groups))}
+	for _, group := range groups {
+		slices.SortFunc(group, compareIds)
+		result.Groups = append(result.Groups, group)
+	}
+	slices.SortFunc(result.Groups, func(a, b []uuid.UUID) int {
+		if len(a) != len(b) {
+			return len(b) - len(a)
+		}
+		return slices.CompareFunc(a, b, compareIds)
+	})
+
+	if live > uint64(len(parent)) {
+		result.Unlinked = live - uint64(len(parent))
+	}
+	return result, nil
+}
+
+// FindCycles finds the cycles formed by links of a directional kind, for
+// example issues that transitively block themselves. The strongly
+// connected components of the kind are found in linear time and each one
+// that has a cycle is reported once, as a shortest cycle through its
+// smallest id. Beyond maxGraphEdges links it returns ErrGraphTooLarge.
+func (repo *IssueRepository) FindCycles(ctx context.Context, kind LinkKind) ([][]uuid.UUID, error) {
+	_, err := ParseLinkKind(string(kind))
+	if err != nil {
+		return nil, err
+	}
+	if kind == kind.Inverse() {
+		return nil, fmt.Errorf("%w: %q has no direction", ErrInvalidLinkKind, kind)
+	}
+
+	edges, err := repo.allEdges(ctx, []LinkKind{kind})
+	if err != nil {
+		return nil, err
+	}
+
+	var successors = make(map[uuid.UUID][]uuid.UUID)
+	for _, edge := range edges {
+		successors[edge.Source] = append(successors[edge.Source], edge.Destination)
+	}
+
+	var result = make([][]uuid.UUID, 0)
+	for _, component := range stronglyConnected(successors) {
+		var start = slices.MinFunc(component, compareIds)
+		if len(component) == 1 && !slices.Contains(successors[start], start) {
+			continue
+		}
+		result = append(result, shortestCycle(successors, component, start))
+	}
+	slices.SortFunc(result, func(a, b []uuid.UUID) int {
+		return compareIds(a[0], b[0])
+	})
+
+	return result, nil
+}
+
+// stronglyConnected is Tarjan's algorithm over the nodes with successors.
+func stronglyConnected(successors map[uuid.UUID][]uuid.UUID) [][]uuid.UUID {
+	var index = make(map[uuid.UUID]int)
+	var lowLink = make(map[uuid.UUID]int)
+	var onStack = make(map[uuid.UUID]bool)
+	var stack = make([]uuid.UUID, 0)
+	var result = make([][]uuid.UUID, 0)
+
+	var connect func(uuid.UUID)
+	connect = func(node uuid.UUID) {
+		index[node] = len(index)
+		lowLink[node] = index[node]
+		stack = append(stack, node)
+		onStack[node] = true
+
+		for _, next := range successors[node] {
+			if _, seen := index[next]; !seen {
+				connect(next)
+				lowLink[node] = min(lowLink[node], lowLink[next])
+			} else if onStack[next] {
+				lowLink[node] = min(lowLink[node], index[next])
+			}
+		}
+
+		if lowLink[node] != index[node] {
+			return
+		}
+		var component = make([]uuid.UUID, 0)
+		for {
+			var top = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == node {
+				break
+			}
+		}
+		result = append(result, component)
+	}
+
+	for node := range successors {
+		if _, seen := index[node]; !seen {
+			connect(node)
+		}
+	}
+	return result
+}
+
+// shortestCycle searches breadth-first inside component for the shortest
+// way from start back to itself.
+func shortestCycle(
+	successors map[uuid.UUID][]uuid.UUID,
+	component []uuid.UUID,
+	start uuid.UUID,
+) []uuid.UUID {
+	var inside = make(map[uuid.UUID]bool, len(component))
+	for _, id := range component {
+		inside[id] = true
+	}
+
+	var previous = map[uuid.UUID]uuid.UUID{}
+	var frontier = []uuid.UUID{start}
+	for len(frontier) > 0 {
+		var next = make([]uuid.UUID, 0)
+		for _, node := range frontier {
+			for _, successor := range successors[node] {
+				if successor == start {
+					var cycle = []uuid.UUID{node}
+					for cycle[0] != start {
+						cycle = slices.Insert(cycle, 0, previous[cycle[0]])
+					}
+					return cycle
+				}
+				if _, seen := previous[successor]; seen || !inside[successor] {
+					continue
+				}
+				previous[successor] = node
+				next = append(next, successor)
+			}
+		}
+		frontier = next
+	}
+	return []uuid.UUID{start}
+}
+
+func (repo *IssueRepository) outgoingEdges(
+	ctx context.Context,
+	ids []uuid.UUID,
+	kinds []LinkKind,
+) ([]Edge, error) {
+	var result = make([]Edge, 0)
+
+	var err = repo.helper.Query(ctx, `
+		DECLARE $ids AS List<Struct<id: Uuid>>;
+		DECLARE $kinds AS List<Text>;
+
+		SELECT l.source AS source, l.destination AS destination, l.kind AS kind
+		FROM links AS l
+		LEFT JOIN issues AS d ON d.id = l.destination
+		WHERE l.source IN (SELECT id FROM AS_TABLE($ids))
+			AND (ListLength($kinds) = 0 OR l.kind IN $kinds)
+			AND d.deleted_at IS NULL;
+		`,
+		ydbQuery.SnapshotReadOnlyTxControl(),
+		ydb.ParamsBuilder().
+			Param("$ids").
+			BeginList().
+			AddItems(
+				utils.Mapped(&ids, func(i int, id uuid.UUID) types.Value {
+					return types.StructValue(
+						types.StructFieldValue("id", types.UuidValue(id)),
+					)
+				})...,
+			).
+			EndList().
+			Param("$kinds").Any(kindsValue(kinds)).
+			Build(),
+		func(rs ydbQuery.ResultSet, ctx context.Context) error {
+			return query.Materialize(rs, ctx, &result)
+		},
+	)
+	if err != nil {
+		return result, classify("outgoingEdges", err)
+	}
+
+	return result, nil
+}
+
+func (repo *IssueRepository) allEdges(ctx context.Context, kinds []LinkKind) ([]Edge, error) {
+	var result = make([]Edge, 0)
+
+	var err = repo.helper.Query(ctx, `
+		DECLARE $kinds AS List<Text>;
+		DECLARE $limit AS Uint64;
+
+		SELECT l.source AS source, l.destination AS destination, l.kind AS kind
+		FROM links AS l
+		LEFT JOIN issues AS s ON s.id = l.source
+		LEFT JOIN issues AS d ON d.id = l.destination
+		WHERE (ListLength($kinds) = 0 OR l.kind IN $kinds)
+			AND s.deleted_at IS NULL AND d.deleted_at IS NULL
+		LIMIT $limit;
+		`,
+		ydbQuery.SnapshotReadOnlyTxControl(),
+		ydb.ParamsBuilder().
+			Param("$kinds").Any(kindsValue(kinds)).
+			Param("$limit").Uint64(maxGraphEdges+1).
+			Build(),
+		func(rs ydbQuery.ResultSet, ctx context.Context) error {
+			return query.Materialize(rs, ctx, &result)
+		},
+	)
+	if err != nil {
+		return result, classify("allEdges", err)
+	}
+	if len(result) > maxGraphEdges {
+		return nil, fmt.Errorf("%w: more than %d links", ErrGraphTooLarge, maxGraphEdges)
+	}
+
+	return result, nil
+}
+
+// linkedGraph returns the links between live issues of kinds together
+// with the number of live issues, both from one snapshot.
+func (repo *IssueRepository) linkedGraph(ctx context.Context, kinds []LinkKind) ([]Edge, uint64, error) {
+	var edges = make([]Edge, 0)
+	var live = make([]countRow, 0)
+
+	var err = repo.helper.Query(ctx, `
+		DECLARE $kinds AS List<Text>;
+		DECLARE $limit AS Uint64;
+
+		SELECT l.source AS source, l.destination AS destination, l.kind AS kind
+		FROM links AS l
+		LEFT JOIN issues AS s ON s.id = l.source
+		LEFT JOIN issues AS d ON d.id = l.destination
+		WHERE (ListLength($kinds) = 0 OR l.kind IN $kinds)
+			AND s.deleted_at IS NULL AND d.deleted_at IS NULL
+		LIMIT $limit;
+
+		SELECT COUNT(*) AS cnt FROM issues
+		WHERE deleted_at IS NULL;
+		`,
+		ydbQuery.SnapshotReadOnlyTxControl(),
+		ydb.ParamsBuilder().
+			Param("$kinds").Any(kindsValue(kinds)).
+			Param("$limit").Uint64(maxGraphEdges+1).
+			Build(),
+		func(rs ydbQuery.ResultSet, ctx context.Context) error {
+			if rs.Index() == 0 {
+				edges = edges[:0]
+				return query.Materialize(rs, ctx, &edges)
+			}
+			live = live[:0]
+			return query.Materialize(rs, ctx, &live)
+		},
+	)
+	if err != nil {
+		return nil, 0, classify("linkedGraph", err)
+	}
+	if len(edges) > maxGraphEdges {
+		return nil, 0, fmt.Errorf("%w: more than %d links", ErrGraphTooLarge, maxGraphEdges)
+	}
+	if len(live) == 0 {
+		return edges, 0, nil
+	}
+
+	return edges, live[0].Count, nil
+}
+
+func kindsValue(kinds []LinkKind) types.Value {
+	if len(kinds) == 0 {
+		return types.ZeroValue(types.List(types.TypeText))
+	}
+	return types.ListValue(utils.Mapped(&kinds, func(i int, kind LinkKind) types.Value {
+		return types.TextValue(string(kind))
+	})...)
+}
+
+func uniqueEdges(edges []Edge) []Edge {
+	var seen = make(map[Edge]bool, len(edges))
+	var result = make([]Edge, 0, len(edges))
+	for _, edge := range edges {
+		if !seen[edge] {
+			seen[edge] = true
+			result = append(result, edge)
+		}
+	}
+	return result
+}
+
+func compareIds(a, b uuid.UUID) int {
+	return slices.Compare(a[:], b[:])
+}
+
+type countRow struct {
+	Count uint64 `sql:"cnt"`
+}
+
+type idRow struct {
+	Id uuid.UUID `sql:"id"`
+}
@@ -0,0 +1,79 @@
+package issue
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// DOT renders the graph in Graphviz syntax, one node per issue and one
+// edge per link labelled with its kind.
+func (g *Graph) DOT() string {
+	var builder strings.Builder
+
+	builder.WriteString("digraph issues {\n")
+	for _, id := range g.sortedIds() {
+		fmt.Fprintf(&builder, "  %q [label=%q];\n", id.String(), g.label(id))
+	}
+	for _, edge := range g.Links() {
+		fmt.Fprintf(
+			&builder,
+			"  %q -> %q [label=%q];\n",
+			edge.Source.String(),
+			edge.Destination.String(),
+			edge.Kind,
+		)
+	}
+	builder.WriteString("}\n")
+
+	return builder.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart.
+func (g *Graph) Mermaid() string {
+	var builder strings.Builder
+
+	builder.WriteString("flowchart LR\n")
+	for _, id := range g.sortedIds() {
+		fmt.Fprintf(&builder, "  %s[\"%s\"]\n", mermaidId(id), mermaidText(g.label(id)))
+	}
+	for _, edge := range g.Links() {
+		fmt.Fprintf(
+			&builder,
+			"  %s -- %s --> %s\n",
+			mermaidId(edge.Source),
+			edge.Kind,
+			mermaidId(edge.Destination),
+		)
+	}
+
+	return builder.String()
+}
+
+func (g *Graph) sortedIds() []uuid.UUID {
+	var ids = make([]uuid.UUID, 0, len(g.Issues))
+	for id := range g.Issues {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, compareIds)
+	return ids
+}
+
+func (g *Graph) label(id uuid.UUID) string {
+	var item = g.Issues[id]
+	if item.Status == "" {
+		return item.Title
+	}
+	return fmt.Sprintf("%s (%s)", item.Title, item.Status)
+}
+
+// Mermaid node ids may not contain dashes.
+func mermaidId(id uuid.UUID) string {
+	return "i" + strings.ReplaceAll(id.String(), "-", "")
+}
+
+func mermaidText(text string) string {
+	return strings.ReplaceAll(text, `"`, "#quot;")
+}
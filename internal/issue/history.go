@@ -0,0 +1,102 @@
+package issue
+
+import (
+	"context"
+	"ydb-sample/internal/query"
+	"ydb-sample/internal/utils"
+
+	"github.com/google/uuid"
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+	ydbQuery "github.com/ydb-platform/ydb-go-sdk/v3/query"
+	"github.com/ydb-platform/ydb-go-sdk/v3/table/types"
+)
+
+// HistoryEntry is one changed column of an issue, ordered by the virtual
+// timestamp (step, tx id) of the change that produced it.
+type HistoryEntry struct {
+	IssueId  uuid.UUID `sql:"issue_id"`
+	Step     uint64    `sql:"step"`
+	TxId     uint64    `sql:"tx_id"`
+	Column   string    `sql:"column_name"`
+	Kind     string    `sql:"kind"`
+	OldValue string    `sql:"old_value"`
+	NewValue string    `sql:"new_value"`
+}
+
+// AppendHistoryInTx writes entries inside tx. Entries are keyed by issue,
+// virtual timestamp and column, so a change projected twice is stored once.
+// Errors are returned unclassified, like UpdateStatusInTx.
+func (repo *IssueRepository) AppendHistoryInTx(
+	ctx context.Context,
+	tx ydbQuery.TxActor,
+	entries []HistoryEntry,
+) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var queryParams = ydb.ParamsBuilder().
+		Param("$entries").
+		BeginList().
+		AddItems(
+			utils.Mapped(&entries, func(i int, entry HistoryEntry) types.Value {
+				return types.StructValue(
+					types.StructFieldValue("issue_id", types.UuidValue(entry.IssueId)),
+					types.StructFieldValue("step", types.Uint64Value(entry.Step)),
+					types.StructFieldValue("tx_id", types.Uint64Value(entry.TxId)),
+					types.StructFieldValue("column_name", types.TextValue(entry.Column)),
+					types.StructFieldValue("kind", types.TextValue(entry.Kind)),
+					types.StructFieldValue("old_value", types.TextValue(entry.OldValue)),
+					types.StructFieldValue("new_value", types.TextValue(entry.NewValue)),
+				)
+			})...,
+		).
+		EndList().
+		Build()
+
+	return tx.Exec(
+		ctx,
+		`
+		DECLARE $entries AS List<Struct<
+			issue_id: Uuid,
+			step: Uint64,
+			tx_id: Uint64,
+			column_name: Text,
+			kind: Text,
+			old_value: Text,
+			new_value: Text,
+		>>;
+
+		UPSERT INTO issue_history
+		SELECT * FROM AS_TABLE($entries);
+		`,
+		ydbQuery.WithParameters(queryParams),
+	)
+}
+
+// History returns the timeline of an issue, oldest change first.
+func (repo *IssueRepository) History(ctx context.Context, id uuid.UUID) ([]HistoryEntry, error) {
+	var result = make([]HistoryEntry, 0)
+
+	var err = repo.helper.Query(ctx, `
+		DECLARE $issue_id AS Uuid;
+
+		SELECT issue_id, step, tx_id, column_name, kind, old_value, new_value
+		FROM issue_history
+		WHERE issue_id = $issue_id
+		ORDER BY step, tx_id, column_name;
+		`,
+		ydbQuery.SnapshotReadOnlyTxControl(),
+		ydb.ParamsBuilder().
+			Param("$issue_id").Uuid(id).
+			Build(),
+		func(rs ydbQuery.ResultSet, ctx context.Context) error {
+			return query.Materialize(rs, ctx, &result)
+		},
+	)
+	if err != nil {
+		return result, classify("History", err)
+	}
+
+	return result, nil
+}
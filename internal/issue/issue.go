@@ -2,15 +2,56 @@ package issue
 
 import (
 	"time"
+	"ydb-sample/internal/utils"
 
 	"github.com/google/uuid"
 )
 
 type Issue struct {
+	Id         uuid.UUID `sql:"id"`
+	Title      string    `sql:"title"`
+	Timestamp  time.Time `sql:"created_at"`
+	Author     string    `sql:"author"`
+	LinksCount uint64    `sql:"links_count"`
+	Status     Status    `sql:"status"`
+
+	StatusChangedAt time.Time `sql:"status_changed_at"`
+	StatusChangedBy string    `sql:"status_changed_by"`
+
+	// DeletedAt is set while the issue is in the trash.
+	DeletedAt time.Time `sql:"deleted_at"`
+}
+
+// issueRow is how an Issue is scanned: the driver only fills plain strings.
+type issueRow struct {
 	Id         uuid.UUID `sql:"id"`
 	Title      string    `sql:"title"`
 	Timestamp  time.Time `sql:"created_at"`
 	Author     string    `sql:"author"`
 	LinksCount uint64    `sql:"links_count"`
 	Status     string    `sql:"status"`
+
+	StatusChangedAt time.Time `sql:"status_changed_at"`
+	StatusChangedBy string    `sql:"status_changed_by"`
+	DeletedAt       time.Time `sql:"deleted_at"`
+}
+
+func (r issueRow) issue() Issue {
+	return Issue{
+		Id:              r.Id,
+		Title:           r.Title,
+		Timestamp:       r.Timestamp,
+		Author:          r.Author,
+		LinksCount:      r.LinksCount,
+		Status:          Status(r.Status),
+		StatusChangedAt: r.StatusChangedAt,
+		StatusChangedBy: r.StatusChangedBy,
+		DeletedAt:       r.DeletedAt,
+	}
+}
+
+func toIssues(rows []issueRow) []Issue {
+	return utils.Mapped(&rows, func(i int, row issueRow) Issue {
+		return row.issue()
+	})
 }
@@ -2,7 +2,8 @@ package issue
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"slices"
 	"time"
 	"ydb-sample/internal/query"
 	"ydb-sample/internal/utils"
@@ -14,23 +15,39 @@ import (
 )
 
 type IssueRepository struct {
-	helper *query.QueryHelper
+	helper      *query.QueryHelper
+	transitions Transitions
 }
 
-func NewIssueRepository(helper *query.QueryHelper) *IssueRepository {
-	return &IssueRepository{
-		helper: helper,
+type Option func(*IssueRepository)
+
+// WithTransitions replaces the default status transition graph.
+func WithTransitions(transitions Transitions) Option {
+	return func(repo *IssueRepository) {
+		repo.transitions = transitions
+	}
+}
+
+func NewIssueRepository(helper *query.QueryHelper, opts ...Option) *IssueRepository {
+	var repo = &IssueRepository{
+		helper:      helper,
+		transitions: DefaultTransitions(),
+	}
+	for _, opt := range opts {
+		opt(repo)
 	}
+	return repo
 }
 
 func (repo *IssueRepository) AddIssue(
+	ctx context.Context,
 	title string,
 	author string,
 ) (*Issue, error) {
 	var uuid = uuid.New()
 	var timestamp = time.Now()
 
-	var err = repo.helper.ExecuteWithParams(`
+	var err = repo.helper.ExecuteWithParams(ctx, `
 		DECLARE $id AS Uuid;
 		DECLARE $title AS Text;
 		DECLARE $created_at AS Timestamp;
@@ -48,7 +65,7 @@ func (repo *IssueRepository) AddIssue(
 			Build(),
 	)
 	if err != nil {
-		return nil, err
+		return nil, classify("AddIssue", err)
 	}
 
 	return &Issue{
@@ -58,7 +75,7 @@ func (repo *IssueRepository) AddIssue(
 	}, nil
 }
 
-func (repo *IssueRepository) AddIssues(issues []string) error {
+func (repo *IssueRepository) AddIssues(ctx context.Context, issues []string) error {
 	var queryParams = ydb.ParamsBuilder().
 		Param("$args").
 		BeginList().
@@ -74,7 +91,7 @@ func (repo *IssueRepository) AddIssues(issues []string) error {
 		EndList().
 		Build()
 
-	return repo.helper.ExecuteWithParams(`
+	var err = repo.helper.ExecuteWithParams(ctx, `
 		DECLARE $args AS List<Struct<
 			id: Uuid,
 			title: Text,
@@ -87,72 +104,95 @@ func (repo *IssueRepository) AddIssues(issues []string) error {
 		ydbQuery.SerializableReadWriteTxControl(ydbQuery.CommitTx()),
 		queryParams,
 	)
+
+	return classify("AddIssues", err)
 }
 
-func (repo *IssueRepository) FindAll() ([]Issue, error) {
-	var result = make([]Issue, 0)
+// FindAll loads every issue at once; large tables are better read with
+// FindPage or Stream.
+func (repo *IssueRepository) FindAll(ctx context.Context, opts ...FindOption) ([]Issue, error) {
+	var rows = make([]issueRow, 0)
+	var options = newFindOptions(opts)
+
+	var err = repo.helper.Query(ctx, `
+		DECLARE $include_deleted AS Bool;
 
-	var err = repo.helper.Query(`
 		SELECT
 			id,
 			title,
 			created_at,
 			author,
 			COALESCE(links_count, 0) AS links_count,
-			status
-		FROM issues;
+			status,
+			status_changed_at,
+			status_changed_by,
+			deleted_at
+		FROM issues
+		WHERE $include_deleted OR deleted_at IS NULL;
 		`,
 		ydbQuery.SnapshotReadOnlyTxControl(),
-		ydb.ParamsBuilder().Build(),
+		ydb.ParamsBuilder().
+			Param("$include_deleted").Bool(options.includeDeleted).
+			Build(),
 		func(rs ydbQuery.ResultSet, ctx context.Context) error {
-			return query.Materialize(rs, ctx, &result)
+			return query.Materialize(rs, ctx, &rows)
 		},
 	)
 	if err != nil {
-		return result, err
+		return nil, classify("FindAll", err)
 	}
 
-	return result, nil
+	return toIssues(rows), nil
 }
 
-func (repo *IssueRepository) FindById(id uuid.UUID) (*Issue, error) {
-	var result = make([]Issue, 0)
+func (repo *IssueRepository) FindById(ctx context.Context, id uuid.UUID, opts ...FindOption) (*Issue, error) {
+	var rows = make([]issueRow, 0)
+	var options = newFindOptions(opts)
+
+	var err = repo.helper.Query(ctx, `
+		DECLARE $id AS Uuid;
+		DECLARE $include_deleted AS Bool;
 
-	var err = repo.helper.Query(`
 		SELECT
 			id,
 			title,
 			created_at,
 			author,
 			COALESCE(links_count, 0) AS links_count,
-			status
+			status,
+			status_changed_at,
+			status_changed_by,
+			deleted_at
 		FROM issues
-		WHERE id=$id;
+		WHERE id=$id AND ($include_deleted OR deleted_at IS NULL);
 		`,
 		ydbQuery.SnapshotReadOnlyTxControl(),
 		ydb.ParamsBuilder().
 			Param("$id").Uuid(id).
+			Param("$include_deleted").Bool(options.includeDeleted).
 			Build(),
 		func(rs ydbQuery.ResultSet, ctx context.Context) error {
-			return query.Materialize(rs, ctx, &result)
+			return query.Materialize(rs, ctx, &rows)
 		},
 	)
 	if err != nil {
-		return nil, err
+		return nil, classify("FindById", err)
 	}
 
-	if len(result) > 1 {
-		return nil, errors.New("Multiple rows with the same id")
+	if len(rows) > 1 {
+		return nil, fmt.Errorf("FindById %s: %w", id, ErrDuplicateId)
 	}
-	if len(result) == 0 {
-		return nil, errors.New("Didnt found any issues (lol)")
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("FindById %s: %w", id, ErrIssueNotFound)
 	}
 
-	return &result[0], nil
+	var result = rows[0].issue()
+	return &result, nil
 }
 
-func (repo *IssueRepository) FindByIds(ids []uuid.UUID) ([]Issue, error) {
-	var result = make([]Issue, 0)
+func (repo *IssueRepository) FindByIds(ctx context.Context, ids []uuid.UUID, opts ...FindOption) ([]Issue, error) {
+	var rows = make([]issueRow, 0)
+	var options = newFindOptions(opts)
 
 	var queryParams = ydb.ParamsBuilder().
 		Param("$ids").
@@ -165,10 +205,12 @@ func (repo *IssueRepository) FindByIds(ids []uuid.UUID) ([]Issue, error) {
 			})...,
 		).
 		EndList().
+		Param("$include_deleted").Bool(options.includeDeleted).
 		Build()
 
-	var err = repo.helper.Query(`
+	var err = repo.helper.Query(ctx, `
 		DECLARE $ids AS List<Struct<id: Uuid>>;
+		DECLARE $include_deleted AS Bool;
 
 		SELECT
 			id,
@@ -176,28 +218,34 @@ func (repo *IssueRepository) FindByIds(ids []uuid.UUID) ([]Issue, error) {
 			created_at,
 			author,
 			links_count,
-			status
+			status,
+			status_changed_at,
+			status_changed_by,
+			deleted_at
 		FROM issues
-		WHERE id IN (SELECT id from AS_TABLE($ids));
+		WHERE id IN (SELECT id from AS_TABLE($ids))
+			AND ($include_deleted OR deleted_at IS NULL);
 		`,
 		ydbQuery.SerializableReadWriteTxControl(ydbQuery.CommitTx()),
 		queryParams,
 		func(rs ydbQuery.ResultSet, ctx context.Context) error {
-			return query.Materialize(rs, ctx, &result)
+			return query.Materialize(rs, ctx, &rows)
 		},
 	)
 	if err != nil {
-		return result, err
+		return nil, classify("FindByIds", err)
 	}
 
-	return result, nil
+	return toIssues(rows), nil
 }
 
-func (repo *IssueRepository) FindByAuthor(author string) ([]Issue, error) {
-	var result = make([]Issue, 0)
+func (repo *IssueRepository) FindByAuthor(ctx context.Context, author string, opts ...FindOption) ([]Issue, error) {
+	var rows = make([]issueRow, 0)
+	var options = newFindOptions(opts)
 
-	var err = repo.helper.Query(`
+	var err = repo.helper.Query(ctx, `
 		DECLARE $author AS Text;
+		DECLARE $include_deleted AS Bool;
 
 		SELECT
 			id,
@@ -205,257 +253,871 @@ func (repo *IssueRepository) FindByAuthor(author string) ([]Issue, error) {
 			created_at,
 			author,
 			COALESCE(links_count, 0) AS links_count,
-			status
+			status,
+			status_changed_at,
+			status_changed_by,
+			deleted_at
 		FROM issues
-		WHERE author=$author
+		WHERE author=$author AND ($include_deleted OR deleted_at IS NULL)
 		`,
 		ydbQuery.SnapshotReadOnlyTxControl(),
 		ydb.ParamsBuilder().
 			Param("$author").Text(author).
+			Param("$include_deleted").Bool(options.includeDeleted).
 			Build(),
 		func(rs ydbQuery.ResultSet, ctx context.Context) error {
-			return query.Materialize(rs, ctx, &result)
+			return query.Materialize(rs, ctx, &rows)
 		},
 	)
 	if err != nil {
-		return result, err
+		return nil, classify("FindByAuthor", err)
 	}
 
-	return result, nil
+	return toIssues(rows), nil
 }
 
-func (repo *IssueRepository) FindFutures() ([]IssueTitle, error) {
+func (repo *IssueRepository) FindFutures(ctx context.Context) ([]IssueTitle, error) {
 	var result = make([]IssueTitle, 0)
 
-	var err = repo.helper.Query(`
-		$future =
-			SELECT id, title
-			FROM issues
-			WHERE status = 'FUTURE';
-		
-		SELECT * from $future;
-
-		UPDATE issues ON
-		SELECT
-			id,
-			CurrentUtcTimestamp() AS created_at,
-			CAST('NEW' AS Text) AS status                                                
-        FROM $future;
+	var err = repo.helper.Query(ctx, `
+		SELECT id, title
+		FROM issues
+		WHERE status = 'FUTURE' AND deleted_at IS NULL;
 		`,
-		ydbQuery.SerializableReadWriteTxControl(ydbQuery.CommitTx()),
+		ydbQuery.SnapshotReadOnlyTxControl(),
 		ydb.ParamsBuilder().Build(),
 		func(rs ydbQuery.ResultSet, ctx context.Context) error {
 			return query.Materialize(rs, ctx, &result)
 		},
 	)
 	if err != nil {
-		return result, err
+		return result, classify("FindFutures", err)
 	}
 
 	return result, nil
 }
 
-func (repo *IssueRepository) UpdateStatus(id uuid.UUID, status string) error {
-	return repo.helper.ExecuteWithParams(`
-		DECLARE $id AS Uuid;
+// PromoteFuturesInTx moves every FUTURE issue to NEW on behalf of actor
+// inside tx and returns the changes, so the caller can record them in the
+// same transaction. created_at is left alone, so promoting does not move
+// issues in the (created_at, id) page order. Errors are returned
+// unclassified, like UpdateStatusInTx.
+func (repo *IssueRepository) PromoteFuturesInTx(
+	ctx context.Context,
+	tx ydbQuery.TxActor,
+	actor string,
+) ([]StatusChange, error) {
+	var err = repo.transitions.check(StatusFuture, StatusNew)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryResultSet(
+		ctx,
+		`
+		SELECT id
+		FROM issues
+		WHERE status = 'FUTURE' AND deleted_at IS NULL;
+		`,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var found = make([]idRow, 0)
+	err = query.Materialize(rows, ctx, &found)
+	if err != nil {
+		return nil, err
+	}
+
+	var changedAt = time.Now()
+	var changes = utils.Mapped(&found, func(i int, row idRow) StatusChange {
+		return StatusChange{
+			Id:        row.Id,
+			From:      StatusFuture,
+			To:        StatusNew,
+			Actor:     actor,
+			ChangedAt: changedAt,
+		}
+	})
+	if len(changes) == 0 {
+		return changes, nil
+	}
+
+	err = tx.Exec(
+		ctx,
+		`
+		DECLARE $ids AS List<Uuid>;
 		DECLARE $new_status AS Text;
+		DECLARE $changed_at AS Timestamp;
+		DECLARE $actor AS Text;
 
 		UPDATE issues
-		SET status = $new_status
-		WHERE id = $id;
+		SET
+			status = $new_status,
+			status_changed_at = $changed_at,
+			status_changed_by = $actor
+		WHERE id IN $ids;
 		`,
-		ydbQuery.SerializableReadWriteTxControl(ydbQuery.CommitTx()),
-		ydb.ParamsBuilder().
-			Param("$id").Uuid(id).
-			Param("$new_status").Text(status).
-			Build(),
+		ydbQuery.WithParameters(
+			ydb.ParamsBuilder().
+				Param("$ids").
+				BeginList().
+				AddItems(
+					utils.Mapped(&found, func(i int, row idRow) types.Value {
+						return types.UuidValue(row.Id)
+					})...,
+				).
+				EndList().
+				Param("$new_status").Text(string(StatusNew)).
+				Param("$changed_at").Timestamp(changedAt).
+				Param("$actor").Text(actor).
+				Build(),
+		),
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
 }
 
-func (repo *IssueRepository) Delete(id uuid.UUID) error {
-	return repo.helper.ExecuteWithParams(`
+// UpdateStatusInTx moves the issue to status if the transition graph
+// allows it. The current status is read in the same serializable
+// transaction, so a concurrent change makes the commit fail instead of
+// being overwritten. Status changes must reach task_status, so callers
+// record the returned change in the outbox of tx; StatusUpdateService in
+// the topic package does. Errors are returned unclassified so that the
+// transaction retrier can still recognise retryable YDB failures.
+func (repo *IssueRepository) UpdateStatusInTx(
+	ctx context.Context,
+	tx ydbQuery.TxActor,
+	id uuid.UUID,
+	status Status,
+	actor string,
+) (*StatusChange, error) {
+	var _, err = ParseStatus(string(status))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryResultSet(
+		ctx,
+		`
 		DECLARE $id AS Uuid;
 
-		DELETE FROM issues WHERE id=$id
+		SELECT status FROM issues
+		WHERE id = $id AND deleted_at IS NULL;
 		`,
-		ydbQuery.SerializableReadWriteTxControl(ydbQuery.CommitTx()),
-		ydb.ParamsBuilder().
-			Param("$id").Uuid(id).
-			Build(),
+		ydbQuery.WithParameters(
+			ydb.ParamsBuilder().
+				Param("$id").Uuid(id).
+				Build(),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var current = make([]issueStatus, 0)
+	err = query.Materialize(rows, ctx, &current)
+	if err != nil {
+		return nil, err
+	}
+	if len(current) == 0 {
+		return nil, fmt.Errorf("%s: %w", id, ErrIssueNotFound)
+	}
+
+	var change = &StatusChange{
+		Id:        id,
+		From:      Status(current[0].Status),
+		To:        status,
+		Actor:     actor,
+		ChangedAt: time.Now(),
+	}
+
+	err = repo.transitions.check(change.From, change.To)
+	if err != nil {
+		return nil, err
+	}
+	if !change.Changed() {
+		return change, nil
+	}
+
+	err = tx.Exec(
+		ctx,
+		`
+		DECLARE $id AS Uuid;
+		DECLARE $new_status AS Text;
+		DECLARE $changed_at AS Timestamp;
+		DECLARE $actor AS Text;
+
+		UPDATE issues
+		SET
+			status = $new_status,
+			status_changed_at = $changed_at,
+			status_changed_by = $actor
+		WHERE id = $id;
+		`,
+		ydbQuery.WithParameters(
+			ydb.ParamsBuilder().
+				Param("$id").Uuid(id).
+				Param("$new_status").Text(string(status)).
+				Param("$changed_at").Timestamp(change.ChangedAt).
+				Param("$actor").Text(actor).
+				Build(),
+		),
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	return change, nil
 }
 
-func (repo *IssueRepository) DeleteByIds(ids []uuid.UUID) error {
-	var queryParams = ydb.ParamsBuilder().
-		Param("$issues_ids_arg").
+// DeletePolicy decides what deleting a linked issue does to its links.
+type DeletePolicy int
+
+const (
+	// DeleteCascade removes the links together with the issue.
+	DeleteCascade DeletePolicy = iota
+	// DeleteRestrict refuses to delete an issue that still has links.
+	DeleteRestrict
+)
+
+// Delete removes an issue with its links in one transaction. With
+// DeleteRestrict an issue that still has links is not deleted and
+// ErrHasLinks is returned; with DeleteCascade the links go too and the
+// counters of the linked issues are decremented.
+func (repo *IssueRepository) Delete(ctx context.Context, id uuid.UUID, policy DeletePolicy) error {
+	var err = repo.helper.ExecuteInTx(
+		ctx,
+		func(ctx context.Context, tx ydbQuery.TxActor) error {
+			return deleteIssues(ctx, tx, []uuid.UUID{id}, policy)
+		},
+	)
+
+	return classify("Delete", err)
+}
+
+// DeleteByIds is Delete for several issues. Links between the deleted
+// issues themselves never block a restricted delete.
+func (repo *IssueRepository) DeleteByIds(ctx context.Context, ids []uuid.UUID, policy DeletePolicy) error {
+	var err = repo.helper.ExecuteInTx(
+		ctx,
+		func(ctx context.Context, tx ydbQuery.TxActor) error {
+			return deleteIssues(ctx, tx, ids, policy)
+		},
+	)
+
+	return classify("DeleteByIds", err)
+}
+
+func deleteIssues(
+	ctx context.Context,
+	tx ydbQuery.TxActor,
+	ids []uuid.UUID,
+	policy DeletePolicy,
+) error {
+	var params = ydb.ParamsBuilder().
+		Param("$ids").
 		BeginList().
 		AddItems(
 			utils.Mapped(&ids, func(i int, id uuid.UUID) types.Value {
-				return types.UuidValue(id)
+				return types.StructValue(
+					types.StructFieldValue("id", types.UuidValue(id)),
+				)
 			})...,
 		).
 		EndList().
 		Build()
 
-	return repo.helper.ExecuteWithParams(`
-			DECLARE $issues_ids_arg AS List<Uuid>;
-
-			$list_to_id_struct = ($id) -> { RETURN <|id:$id|> };
-
-			$issue_ids_list = ListMap(ListUniq($issues_ids_arg), $list_to_id_struct);
-
-			$issues = SELECT id FROM AS_TABLE($issue_ids_list);
-
-			$linked_issues = 
-				SELECT
-					source,
-					destination
-				FROM links
-				WHERE source IN $issues;
-			
-			$linked_issues_mirrored =
-				SELECT
-					destination AS source,
-					source AS destination
-				FROM $linked_issues;
-			
-			$mirrored_dec_map = 
-				SELECT
-					source AS id,
-					COUNT(*) as cnt
-				FROM $linked_issues_mirrored
-				GROUP BY source;
-			
-			UPDATE issues ON
-			SELECT
-				i.id as id,
-				i.links_count - d.cnt AS links_count
-			FROM $mirrored_dec_map AS d
-			JOIN issues AS i ON d.id = i.id;
-
-			UPDATE issues
-			SET links_count = links_count - 1
-			WHERE id IN $issues;
-
-			DELETE FROM issues
-			WHERE id IN $issues;
+	if policy == DeleteRestrict {
+		rows, err := tx.QueryResultSet(
+			ctx,
+			`
+			DECLARE $ids AS List<Struct<id: Uuid>>;
+
+			$deleted = SELECT id FROM AS_TABLE($ids);
+
+			SELECT source, destination, kind
+			FROM links
+			WHERE source IN $deleted AND destination NOT IN $deleted
+			LIMIT 1;
+			`,
+			ydbQuery.WithParameters(params),
+		)
+		if err != nil {
+			return err
+		}
+
+		var found = make([]Edge, 0)
+		err = query.Materialize(rows, ctx, &found)
+		if err != nil {
+			return err
+		}
+		if len(found) > 0 {
+			return fmt.Errorf(
+				"%w: %s %s %s",
+				ErrHasLinks,
+				found[0].Source,
+				found[0].Kind,
+				found[0].Destination,
+			)
+		}
+	}
+
+	// The neighbours' own rows of the links are counted, so their
+	// per-kind counters are decremented under the kind they store.
+	// Trashed issues were already subtracted when they were trashed.
+	return tx.Exec(
+		ctx,
+		`
+		DECLARE $ids AS List<Struct<id: Uuid>>;
+
+		$deleted = SELECT id FROM AS_TABLE($ids);
+
+		$live_deleted =
+			SELECT id FROM issues
+			WHERE id IN $deleted AND deleted_at IS NULL;
+
+		$neighbour_links =
+			SELECT source AS issue_id, kind, COUNT(*) AS cnt
+			FROM links
+			WHERE destination IN $live_deleted AND source NOT IN $deleted
+			GROUP BY source, kind;
+
+		$neighbour_totals =
+			SELECT issue_id, SUM(cnt) AS cnt
+			FROM $neighbour_links
+			GROUP BY issue_id;
+
+		UPDATE issues ON
+		SELECT
+			i.id AS id,
+			IF(COALESCE(i.links_count, 0ul) > t.cnt, COALESCE(i.links_count, 0ul) - t.cnt, 0ul) AS links_count
+		FROM $neighbour_totals AS t
+		JOIN issues AS i ON i.id = t.issue_id;
+
+		UPDATE link_counts ON
+		SELECT
+			c.issue_id AS issue_id,
+			c.kind AS kind,
+			IF(c.links_count > n.cnt, c.links_count - n.cnt, 0ul) AS links_count
+		FROM $neighbour_links AS n
+		JOIN link_counts AS c ON c.issue_id = n.issue_id AND c.kind = n.kind;
+
+		DELETE FROM links
+		WHERE source IN $deleted OR destination IN $deleted;
+
+		DELETE FROM link_counts
+		WHERE issue_id IN $deleted;
+
+		DELETE FROM issues
+		WHERE id IN $deleted;
 		`,
-		ydbQuery.SerializableReadWriteTxControl(ydbQuery.CommitTx()),
-		queryParams,
+		ydbQuery.WithParameters(params),
 	)
 }
 
+// LinkTicketsNoInteractive links id1 and id2 as relates-to in a single
+// query. Linking issues that are already related changes nothing. Like
+// Link it returns ErrIssueNotFound when either issue is missing or
+// trashed, and ErrSelfLink for an issue linked to itself.
 func (repo *IssueRepository) LinkTicketsNoInteractive(
+	ctx context.Context,
 	id1 uuid.UUID,
 	id2 uuid.UUID,
 ) ([]IssueLinksCount, error) {
 	var result = make([]IssueLinksCount, 0)
 
-	var err = repo.helper.Query(`
+	if id1 == id2 {
+		return result, fmt.Errorf("%w: %s", ErrSelfLink, id1)
+	}
+
+	var err = repo.helper.Query(ctx, `
 		DECLARE $t1 as Uuid;
 		DECLARE $t2 as Uuid;
+		DECLARE $kind as Text;
+
+		$linked = (
+			SELECT COUNT(*) FROM links
+			WHERE source = $t1 AND destination = $t2 AND kind = $kind
+		);
+
+		$t1_live = (SELECT COUNT(*) FROM issues WHERE id = $t1 AND deleted_at IS NULL);
+		$t2_live = (SELECT COUNT(*) FROM issues WHERE id = $t2 AND deleted_at IS NULL);
+		$add = $linked = 0 AND $t1_live > 0 AND $t2_live > 0;
 
 		UPDATE issues
 		SET links_count = COALESCE(links_count, 0) + 1
-		WHERE id IN ($t1, $t2);
+		WHERE id IN ($t1, $t2) AND $add;
 
-		INSERT INTO links (source, destination)
-		VALUES ($t1, $t2), ($t2, $t1);
+		UPSERT INTO link_counts
+		SELECT
+			t.issue_id AS issue_id,
+			t.kind AS kind,
+			COALESCE(c.links_count, 0ul) + 1ul AS links_count
+		FROM AS_TABLE(AsList(
+			AsStruct($t1 AS issue_id, $kind AS kind),
+			AsStruct($t2 AS issue_id, $kind AS kind)
+		)) AS t
+		LEFT JOIN link_counts AS c ON c.issue_id = t.issue_id AND c.kind = t.kind
+		WHERE $add;
+
+		UPSERT INTO links
+		SELECT * FROM AS_TABLE(AsList(
+			AsStruct($t1 AS source, $t2 AS destination, $kind AS kind),
+			AsStruct($t2 AS source, $t1 AS destination, $kind AS kind)
+		))
+		WHERE $add;
 
 		SELECT id, links_count FROM issues
-		WHERE id in ($t1, $t2);
+		WHERE id in ($t1, $t2) AND deleted_at IS NULL;
 		`,
 		ydbQuery.SerializableReadWriteTxControl(ydbQuery.CommitTx()),
 		ydb.ParamsBuilder().
 			Param("$t1").Uuid(id1).
 			Param("$t2").Uuid(id2).
+			Param("$kind").Text(string(LinkRelatesTo)).
 			Build(),
 		func(rs ydbQuery.ResultSet, ctx context.Context) error {
+			result = result[:0]
 			return query.Materialize(rs, ctx, &result)
 		},
 	)
 	if err != nil {
-		return result, err
+		return result, classify("LinkTicketsNoInteractive", err)
+	}
+
+	// Only live issues are returned, so a missing one means nothing was
+	// linked.
+	for _, id := range []uuid.UUID{id1, id2} {
+		if !slices.ContainsFunc(result, func(count IssueLinksCount) bool { return count.Id == id }) {
+			return result, fmt.Errorf("LinkTicketsNoInteractive %s: %w", id, ErrIssueNotFound)
+		}
 	}
 
 	return result, nil
 }
 
+// LinkTicketsInteractive links id1 and id2 as relates-to.
 func (repo *IssueRepository) LinkTicketsInteractive(
+	ctx context.Context,
 	id1 uuid.UUID,
 	id2 uuid.UUID,
 ) ([]IssueLinksCount, error) {
 	var result = make([]IssueLinksCount, 0)
 
 	var err = repo.helper.ExecuteInTx(
+		ctx,
 		func(ctx context.Context, tx ydbQuery.TxActor) error {
-			var err = tx.Exec(
-				ctx,
-				`
-				DECLARE $t1 AS Uuid;
-				DECLARE $t2 AS Uuid;
-
-				UPDATE issues
-				SET links_count = COALESCE(links_count, 0) + 1
-				WHERE id in ($t1, $t2);
-				`,
-				ydbQuery.WithParameters(
-					ydb.ParamsBuilder().
-						Param("$t1").Uuid(id1).
-						Param("$t2").Uuid(id2).
-						Build(),
-				),
-			)
+			var err = link(ctx, tx, id1, LinkRelatesTo, id2)
 			if err != nil {
 				return err
 			}
 
-			err = tx.Exec(
-				ctx,
-				`
-				DECLARE $t1 as Uuid;
-				DECLARE $t2 as Uuid;
-
-				INSERT INTO links (source, destination)
-				VALUES ($t1, $t2), ($t2, $t1);
-				`,
-				ydbQuery.WithParameters(
-					ydb.ParamsBuilder().
-						Param("$t1").Uuid(id1).
-						Param("$t2").Uuid(id2).
-						Build(),
-				),
-			)
+			return linksCounts(ctx, tx, id1, id2, &result)
+		},
+	)
+	if err != nil {
+		return result, classify("LinkTicketsInteractive", err)
+	}
+
+	return result, nil
+}
+
+// Link records that id1 relates to id2 as kind, and id2 to id1 as the
+// inverse kind. Creating an existing link changes nothing.
+func (repo *IssueRepository) Link(
+	ctx context.Context,
+	id1 uuid.UUID,
+	kind LinkKind,
+	id2 uuid.UUID,
+) ([]IssueLinksCount, error) {
+	var result = make([]IssueLinksCount, 0)
+
+	var _, err = ParseLinkKind(string(kind))
+	if err != nil {
+		return result, err
+	}
+
+	err = repo.helper.ExecuteInTx(
+		ctx,
+		func(ctx context.Context, tx ydbQuery.TxActor) error {
+			var err = link(ctx, tx, id1, kind, id2)
 			if err != nil {
 				return err
 			}
 
-			rows, err := tx.QueryResultSet(
-				ctx,
-				`
-				DECLARE $t1 as Uuid;
-				DECLARE $t2 as Uuid;
-
-				SELECT id, links_count FROM issues
-				WHERE id IN ($t1, $t2);
-				`,
-				ydbQuery.WithParameters(
-					ydb.ParamsBuilder().
-						Param("$t1").Uuid(id1).
-						Param("$t2").Uuid(id2).
-						Build(),
-				),
-			)
+			return linksCounts(ctx, tx, id1, id2, &result)
+		},
+	)
+	if err != nil {
+		return result, classify("Link", err)
+	}
+
+	return result, nil
+}
+
+// Unlink removes the kind link from id1 to id2 together with its inverse.
+// Removing a missing link changes nothing.
+func (repo *IssueRepository) Unlink(
+	ctx context.Context,
+	id1 uuid.UUID,
+	kind LinkKind,
+	id2 uuid.UUID,
+) ([]IssueLinksCount, error) {
+	var result = make([]IssueLinksCount, 0)
+
+	var _, err = ParseLinkKind(string(kind))
+	if err != nil {
+		return result, err
+	}
+
+	err = repo.helper.ExecuteInTx(
+		ctx,
+		func(ctx context.Context, tx ydbQuery.TxActor) error {
+			var err = unlink(ctx, tx, id1, kind, id2)
 			if err != nil {
 				return err
 			}
 
-			return query.Materialize(rows, ctx, &result)
+			return linksCounts(ctx, tx, id1, id2, &result)
 		},
 	)
 	if err != nil {
-		return result, err
+		return result, classify("Unlink", err)
+	}
+
+	return result, nil
+}
+
+// UnlinkTickets removes every link between id1 and id2, whatever its kind,
+// in both directions and decrements the counters. Unlinking issues that
+// are not linked changes nothing.
+func (repo *IssueRepository) UnlinkTickets(
+	ctx context.Context,
+	id1 uuid.UUID,
+	id2 uuid.UUID,
+) ([]IssueLinksCount, error) {
+	var result = make([]IssueLinksCount, 0)
+
+	var err = repo.helper.ExecuteInTx(
+		ctx,
+		func(ctx context.Context, tx ydbQuery.TxActor) error {
+			kinds, err := linkKinds(ctx, tx, id1, id2)
+			if err != nil {
+				return err
+			}
+
+			for _, kind := range kinds {
+				err = unlink(ctx, tx, id1, kind, id2)
+				if err != nil {
+					return err
+				}
+			}
+
+			return linksCounts(ctx, tx, id1, id2, &result)
+		},
+	)
+	if err != nil {
+		return result, classify("UnlinkTickets", err)
 	}
 
 	return result, nil
 }
+
+// FindLinked returns the issues linked to id ordered by title. Kind is the
+// relation of id to the linked issue.
+func (repo *IssueRepository) FindLinked(ctx context.Context, id uuid.UUID) ([]LinkedIssue, error) {
+	var result = make([]LinkedIssue, 0)
+
+	var err = repo.helper.Query(ctx, `
+		DECLARE $id AS Uuid;
+
+		SELECT
+			i.id AS id,
+			i.title AS title,
+			i.status AS status,
+			l.kind AS kind
+		FROM links AS l
+		JOIN issues AS i ON i.id = l.destination
+		WHERE l.source = $id AND i.deleted_at IS NULL
+		ORDER BY title, id, kind;
+		`,
+		ydbQuery.SnapshotReadOnlyTxControl(),
+		ydb.ParamsBuilder().
+			Param("$id").Uuid(id).
+			Build(),
+		func(rs ydbQuery.ResultSet, ctx context.Context) error {
+			return query.Materialize(rs, ctx, &result)
+		},
+	)
+	if err != nil {
+		return result, classify("FindLinked", err)
+	}
+
+	return result, nil
+}
+
+// FindLinkedByKind returns the issues id is linked to as kind, for example
+// the issues it blocks for LinkBlocks.
+func (repo *IssueRepository) FindLinkedByKind(
+	ctx context.Context,
+	id uuid.UUID,
+	kind LinkKind,
+) ([]LinkedIssue, error) {
+	var result = make([]LinkedIssue, 0)
+
+	var err = repo.helper.Query(ctx, `
+		DECLARE $id AS Uuid;
+		DECLARE $kind AS Text;
+
+		SELECT
+			i.id AS id,
+			i.title AS title,
+			i.status AS status,
+			l.kind AS kind
+		FROM links AS l
+		JOIN issues AS i ON i.id = l.destination
+		WHERE l.source = $id AND l.kind = $kind AND i.deleted_at IS NULL
+		ORDER BY title, id;
+		`,
+		ydbQuery.SnapshotReadOnlyTxControl(),
+		ydb.ParamsBuilder().
+			Param("$id").Uuid(id).
+			Param("$kind").Text(string(kind)).
+			Build(),
+		func(rs ydbQuery.ResultSet, ctx context.Context) error {
+			return query.Materialize(rs, ctx, &result)
+		},
+	)
+	if err != nil {
+		return result, classify("FindLinkedByKind", err)
+	}
+
+	return result, nil
+}
+
+// LinkCounts returns the number of links of id per kind.
+func (repo *IssueRepository) LinkCounts(ctx context.Context, id uuid.UUID) ([]LinkKindCount, error) {
+	var result = make([]LinkKindCount, 0)
+
+	var err = repo.helper.Query(ctx, `
+		DECLARE $id AS Uuid;
+
+		SELECT kind, links_count
+		FROM link_counts
+		WHERE issue_id = $id AND links_count > 0
+		ORDER BY kind;
+		`,
+		ydbQuery.SnapshotReadOnlyTxControl(),
+		ydb.ParamsBuilder().
+			Param("$id").Uuid(id).
+			Build(),
+		func(rs ydbQuery.ResultSet, ctx context.Context) error {
+			return query.Materialize(rs, ctx, &result)
+		},
+	)
+	if err != nil {
+		return result, classify("LinkCounts", err)
+	}
+
+	return result, nil
+}
+
+// link stores both directions of a new link and bumps the total and the
+// per-kind counters of both issues. Trashed issues cannot be linked, and
+// neither can an issue to itself.
+func link(
+	ctx context.Context,
+	tx ydbQuery.TxActor,
+	id1 uuid.UUID,
+	kind LinkKind,
+	id2 uuid.UUID,
+) error {
+	if id1 == id2 {
+		return fmt.Errorf("%w: %s", ErrSelfLink, id1)
+	}
+
+	live, err := liveIds(ctx, tx, id1, id2)
+	if err != nil {
+		return err
+	}
+	for _, id := range []uuid.UUID{id1, id2} {
+		if !live[id] {
+			return fmt.Errorf("%w: %s", ErrIssueNotFound, id)
+		}
+	}
+
+	kinds, err := linkKinds(ctx, tx, id1, id2)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(kinds, kind) {
+		return nil
+	}
+
+	return tx.Exec(
+		ctx,
+		`
+		DECLARE $t1 AS Uuid;
+		DECLARE $t2 AS Uuid;
+		DECLARE $kind AS Text;
+		DECLARE $inverse AS Text;
+
+		UPDATE issues
+		SET links_count = COALESCE(links_count, 0) + 1
+		WHERE id IN ($t1, $t2);
+
+		UPSERT INTO link_counts
+		SELECT
+			t.issue_id AS issue_id,
+			t.kind AS kind,
+			COALESCE(c.links_count, 0ul) + 1ul AS links_count
+		FROM AS_TABLE(AsList(
+			AsStruct($t1 AS issue_id, $kind AS kind),
+			AsStruct($t2 AS issue_id, $inverse AS kind)
+		)) AS t
+		LEFT JOIN link_counts AS c ON c.issue_id = t.issue_id AND c.kind = t.kind;
+
+		UPSERT INTO links (source, destination, kind)
+		VALUES ($t1, $t2, $kind), ($t2, $t1, $inverse);
+		`,
+		ydbQuery.WithParameters(
+			ydb.ParamsBuilder().
+				Param("$t1").Uuid(id1).
+				Param("$t2").Uuid(id2).
+				Param("$kind").Text(string(kind)).
+				Param("$inverse").Text(string(kind.Inverse())).
+				Build(),
+		),
+	)
+}
+
+// unlink is the reverse of link. A counter only drops if the other end
+// is live: links to trashed issues are not counted.
+func unlink(
+	ctx context.Context,
+	tx ydbQuery.TxActor,
+	id1 uuid.UUID,
+	kind LinkKind,
+	id2 uuid.UUID,
+) error {
+	kinds, err := linkKinds(ctx, tx, id1, id2)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(kinds, kind) {
+		return nil
+	}
+
+	live, err := liveIds(ctx, tx, id1, id2)
+	if err != nil {
+		return err
+	}
+
+	return tx.Exec(
+		ctx,
+		`
+		DECLARE $t1 AS Uuid;
+		DECLARE $t2 AS Uuid;
+		DECLARE $kind AS Text;
+		DECLARE $inverse AS Text;
+		DECLARE $t1_live AS Bool;
+		DECLARE $t2_live AS Bool;
+
+		DELETE FROM links
+		WHERE (source = $t1 AND destination = $t2 AND kind = $kind)
+			OR (source = $t2 AND destination = $t1 AND kind = $inverse);
+
+		UPDATE issues
+		SET links_count = links_count - 1
+		WHERE ((id = $t1 AND $t2_live) OR (id = $t2 AND $t1_live)) AND links_count > 0;
+
+		UPDATE link_counts
+		SET links_count = links_count - 1
+		WHERE ((issue_id = $t1 AND kind = $kind AND $t2_live) OR (issue_id = $t2 AND kind = $inverse AND $t1_live))
+			AND links_count > 0;
+		`,
+		ydbQuery.WithParameters(
+			ydb.ParamsBuilder().
+				Param("$t1").Uuid(id1).
+				Param("$t2").Uuid(id2).
+				Param("$kind").Text(string(kind)).
+				Param("$inverse").Text(string(kind.Inverse())).
+				Param("$t1_live").Bool(live[id1]).
+				Param("$t2_live").Bool(live[id2]).
+				Build(),
+		),
+	)
+}
+
+// linkKinds returns the kinds of the links from id1 to id2.
+func linkKinds(
+	ctx context.Context,
+	tx ydbQuery.TxActor,
+	id1 uuid.UUID,
+	id2 uuid.UUID,
+) ([]LinkKind, error) {
+	rows, err := tx.QueryResultSet(
+		ctx,
+		`
+		DECLARE $t1 AS Uuid;
+		DECLARE $t2 AS Uuid;
+
+		SELECT kind FROM links
+		WHERE source = $t1 AND destination = $t2;
+		`,
+		ydbQuery.WithParameters(
+			ydb.ParamsBuilder().
+				Param("$t1").Uuid(id1).
+				Param("$t2").Uuid(id2).
+				Build(),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var found = make([]linkKindRow, 0)
+	err = query.Materialize(rows, ctx, &found)
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.Mapped(&found, func(i int, row linkKindRow) LinkKind {
+		return LinkKind(row.Kind)
+	}), nil
+}
+
+func linksCounts(
+	ctx context.Context,
+	tx ydbQuery.TxActor,
+	id1 uuid.UUID,
+	id2 uuid.UUID,
+	result *[]IssueLinksCount,
+) error {
+	rows, err := tx.QueryResultSet(
+		ctx,
+		`
+		DECLARE $t1 as Uuid;
+		DECLARE $t2 as Uuid;
+
+		SELECT id, links_count FROM issues
+		WHERE id IN ($t1, $t2);
+		`,
+		ydbQuery.WithParameters(
+			ydb.ParamsBuilder().
+				Param("$t1").Uuid(id1).
+				Param("$t2").Uuid(id2).
+				Build(),
+		),
+	)
+	if err != nil {
+		return err
+	}
+
+	return query.Materialize(rows, ctx, result)
+}
@@ -0,0 +1,229 @@
+package issue
+
+import (
+	"context"
+	"slices"
+	"ydb-sample/internal/query"
+	"ydb-sample/internal/utils"
+
+	"github.com/google/uuid"
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+	ydbQuery "github.com/ydb-platform/ydb-go-sdk/v3/query"
+	"github.com/ydb-platform/ydb-go-sdk/v3/table/types"
+)
+
+const defaultRepairBatchSize = 100
+
+// LinkCountDrift is a stored link counter that differs from the number of
+// links rows. An empty Kind refers to issues.links_count, any other kind
+// to link_counts.
+type LinkCountDrift struct {
+	IssueId uuid.UUID `sql:"issue_id"`
+	Kind    string    `sql:"kind"`
+	Stored  uint64    `sql:"stored"`
+	Actual  uint64    `sql:"actual"`
+}
+
+// LinkReport is the result of VerifyLinks. Dangling links have a source or
+// destination that is no longer in issues; they are not counted in Actual.
+type LinkReport struct {
+	Drift    []LinkCountDrift
+	Dangling []Edge
+}
+
+func (r *LinkReport) Consistent() bool {
+	return len(r.Drift) == 0 && len(r.Dangling) == 0
+}
+
+// VerifyLinks recomputes the link counters from links and reports drift
+// and dangling links. It only reads, from one snapshot. Links to trashed
+// issues are not counted.
+func (repo *IssueRepository) VerifyLinks(ctx context.Context) (*LinkReport, error) {
+	var report = &LinkReport{
+		Drift:    make([]LinkCountDrift, 0),
+		Dangling: make([]Edge, 0),
+	}
+
+	var err = repo.helper.Query(ctx, `
+		SELECT l.source AS source, l.destination AS destination, l.kind AS kind
+		FROM links AS l
+		LEFT JOIN issues AS s ON s.id = l.source
+		LEFT JOIN issues AS d ON d.id = l.destination
+		WHERE s.id IS NULL OR d.id IS NULL
+		ORDER BY source, destination, kind;
+
+		$actual = SELECT l.source AS source, l.kind AS kind, COUNT(*) AS cnt
+			FROM links AS l
+			JOIN issues AS d ON d.id = l.destination
+			WHERE d.deleted_at IS NULL
+			GROUP BY l.source, l.kind;
+
+		$actual_total = SELECT source, SUM(cnt) AS cnt
+			FROM $actual
+			GROUP BY source;
+
+		SELECT
+			i.id AS issue_id,
+			""u AS kind,
+			COALESCE(i.links_count, 0ul) AS stored,
+			COALESCE(a.cnt, 0ul) AS actual
+		FROM issues AS i
+		LEFT JOIN $actual_total AS a ON a.source = i.id
+		WHERE COALESCE(i.links_count, 0ul) != COALESCE(a.cnt, 0ul)
+
+		UNION ALL
+
+		SELECT
+			COALESCE(c.issue_id, a.source) AS issue_id,
+			COALESCE(c.kind, a.kind) AS kind,
+			COALESCE(c.links_count, 0ul) AS stored,
+			COALESCE(a.cnt, 0ul) AS actual
+		FROM link_counts AS c
+		FULL JOIN $actual AS a ON a.source = c.issue_id AND a.kind = c.kind
+		WHERE COALESCE(c.links_count, 0ul) != COALESCE(a.cnt, 0ul)
+
+		ORDER BY issue_id, kind;
+		`,
+		ydbQuery.SnapshotReadOnlyTxControl(),
+		ydb.ParamsBuilder().Build(),
+		func(rs ydbQuery.ResultSet, ctx context.Context) error {
+			// A retried query delivers both result sets again, so each
+			// one replaces what an earlier attempt left behind.
+			if rs.Index() == 0 {
+				report.Dangling = report.Dangling[:0]
+				return query.Materialize(rs, ctx, &report.Dangling)
+			}
+			report.Drift = report.Drift[:0]
+			return query.Materialize(rs, ctx, &report.Drift)
+		},
+	)
+	if err != nil {
+		return nil, classify("VerifyLinks", err)
+	}
+
+	return report, nil
+}
+
+// RepairLinks fixes what VerifyLinks reported: it deletes dangling links
+// and recomputes the counters of every affected issue. Work is split into
+// transactions of at most batchSize links or issues, and counters are
+// recomputed inside each transaction, so links changed since the report
+// are counted correctly.
+func (repo *IssueRepository) RepairLinks(
+	ctx context.Context,
+	report *LinkReport,
+	batchSize int,
+) error {
+	if batchSize <= 0 {
+		batchSize = defaultRepairBatchSize
+	}
+
+	for batch := range slices.Chunk(report.Dangling, batchSize) {
+		var err = repo.deleteLinks(ctx, batch)
+		if err != nil {
+			return err
+		}
+	}
+
+	var seen = make(map[uuid.UUID]bool)
+	var ids = make([]uuid.UUID, 0)
+	var add = func(id uuid.UUID) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, drift := range report.Drift {
+		add(drift.IssueId)
+	}
+	for _, edge := range report.Dangling {
+		add(edge.Source)
+		add(edge.Destination)
+	}
+
+	for batch := range slices.Chunk(ids, batchSize) {
+		var err = repo.recountLinks(ctx, batch)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (repo *IssueRepository) deleteLinks(ctx context.Context, edges []Edge) error {
+	var err = repo.helper.ExecuteWithParams(ctx, `
+		DECLARE $links AS List<Struct<
+			source: Uuid,
+			destination: Uuid,
+			kind: Text,
+		>>;
+
+		DELETE FROM links ON
+		SELECT * FROM AS_TABLE($links);
+		`,
+		ydbQuery.SerializableReadWriteTxControl(ydbQuery.CommitTx()),
+		ydb.ParamsBuilder().
+			Param("$links").
+			BeginList().
+			AddItems(
+				utils.Mapped(&edges, func(i int, edge Edge) types.Value {
+					return types.StructValue(
+						types.StructFieldValue("source", types.UuidValue(edge.Source)),
+						types.StructFieldValue("destination", types.UuidValue(edge.Destination)),
+						types.StructFieldValue("kind", types.TextValue(edge.Kind)),
+					)
+				})...,
+			).
+			EndList().
+			Build(),
+	)
+
+	return classify("deleteLinks", err)
+}
+
+// recountLinks sets links_count and link_counts of ids to what links
+// holds right now. Counters of issues that no longer exist are dropped.
+func (repo *IssueRepository) recountLinks(ctx context.Context, ids []uuid.UUID) error {
+	var err = repo.helper.ExecuteWithParams(ctx, `
+		DECLARE $ids AS List<Struct<id: Uuid>>;
+
+		$actual = SELECT l.source AS issue_id, l.kind AS kind, COUNT(*) AS links_count
+			FROM links AS l
+			JOIN issues AS d ON d.id = l.destination
+			WHERE l.source IN (SELECT id FROM AS_TABLE($ids)) AND d.deleted_at IS NULL
+			GROUP BY l.source, l.kind;
+
+		$actual_total = SELECT issue_id, SUM(links_count) AS links_count
+			FROM $actual
+			GROUP BY issue_id;
+
+		UPDATE issues ON
+		SELECT i.id AS id, COALESCE(a.links_count, 0ul) AS links_count
+		FROM issues AS i
+		LEFT JOIN $actual_total AS a ON a.issue_id = i.id
+		WHERE i.id IN (SELECT id FROM AS_TABLE($ids));
+
+		DELETE FROM link_counts
+		WHERE issue_id IN (SELECT id FROM AS_TABLE($ids));
+
+		UPSERT INTO link_counts
+		SELECT issue_id, kind, links_count FROM $actual;
+		`,
+		ydbQuery.SerializableReadWriteTxControl(ydbQuery.CommitTx()),
+		ydb.ParamsBuilder().
+			Param("$ids").
+			BeginList().
+			AddItems(
+				utils.Mapped(&ids, func(i int, id uuid.UUID) types.Value {
+					return types.StructValue(
+						types.StructFieldValue("id", types.UuidValue(id)),
+					)
+				})...,
+			).
+			EndList().
+			Build(),
+	)
+
+	return classify("recountLinks", err)
+}
@@ -0,0 +1,63 @@
+package issue
+
+import (
+	"fmt"
+	"slices"
+)
+
+// LinkKind is the relation of a link as seen from its source issue. Every
+// link is stored twice: once with its kind and once in the opposite
+// direction with the inverse kind.
+type LinkKind string
+
+const (
+	LinkBlocks       LinkKind = "blocks"
+	LinkBlockedBy    LinkKind = "blocked-by"
+	LinkDuplicates   LinkKind = "duplicates"
+	LinkDuplicatedBy LinkKind = "duplicated-by"
+	LinkRelatesTo    LinkKind = "relates-to"
+	LinkParentOf     LinkKind = "parent-of"
+	LinkChildOf      LinkKind = "child-of"
+)
+
+var linkInverses = map[LinkKind]LinkKind{
+	LinkBlocks:       LinkBlockedBy,
+	LinkBlockedBy:    LinkBlocks,
+	LinkDuplicates:   LinkDuplicatedBy,
+	LinkDuplicatedBy: LinkDuplicates,
+	LinkRelatesTo:    LinkRelatesTo,
+	LinkParentOf:     LinkChildOf,
+	LinkChildOf:      LinkParentOf,
+}
+
+func ParseLinkKind(value string) (LinkKind, error) {
+	var kind = LinkKind(value)
+	if _, ok := linkInverses[kind]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrInvalidLinkKind, value)
+	}
+	return kind, nil
+}
+
+// LinkKinds lists every known kind in a stable order.
+func LinkKinds() []LinkKind {
+	var kinds = make([]LinkKind, 0, len(linkInverses))
+	for kind := range linkInverses {
+		kinds = append(kinds, kind)
+	}
+	slices.Sort(kinds)
+	return kinds
+}
+
+// Inverse is the kind of the same link seen from its destination.
+func (k LinkKind) Inverse() LinkKind {
+	return linkInverses[k]
+}
+
+type LinkKindCount struct {
+	Kind       string `sql:"kind"`
+	LinksCount uint64 `sql:"links_count"`
+}
+
+type linkKindRow struct {
+	Kind string `sql:"kind"`
+}
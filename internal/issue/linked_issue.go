@@ -0,0 +1,10 @@
+package issue
+
+import "github.com/google/uuid"
+
+type LinkedIssue struct {
+	Id     uuid.UUID `sql:"id"`
+	Title  string    `sql:"title"`
+	Status string    `sql:"status"`
+	Kind   string    `sql:"kind"`
+}
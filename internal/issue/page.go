@@ -0,0 +1,300 @@
+package issue
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+	ydbQuery "github.com/ydb-platform/ydb-go-sdk/v3/query"
+	"github.com/ydb-platform/ydb-go-sdk/v3/sugar"
+	"github.com/ydb-platform/ydb-go-sdk/v3/table/types"
+)
+
+const defaultPageSize = 100
+
+// errStopped aborts a page query once the consumer wants no more rows.
+var errStopped = errors.New("iteration stopped")
+
+// Cursor points right after an issue in (created_at, id) order. Callers
+// must treat it as opaque; the empty cursor is the start of the table.
+type Cursor string
+
+// Page is one page of issues. Next is empty on the last page.
+type Page struct {
+	Issues []Issue
+	Next   Cursor
+}
+
+// WithPageSize sets how many issues a page holds, or a streaming read
+// fetches per query. The default is 100.
+func WithPageSize(size int) FindOption {
+	return func(o *findOptions) {
+		o.pageSize = size
+	}
+}
+
+type pageKey struct {
+	createdAt time.Time
+	id        uuid.UUID
+}
+
+func keyOf(issue *Issue) pageKey {
+	return pageKey{createdAt: issue.Timestamp, id: issue.Id}
+}
+
+func (k pageKey) cursor() Cursor {
+	var raw = strconv.FormatInt(k.createdAt.UnixMicro(), 10) + "/" + k.id.String()
+	return Cursor(base64.RawURLEncoding.EncodeToString([]byte(raw)))
+}
+
+func parseCursor(cursor Cursor) (*pageKey, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(string(cursor))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+
+	micros, id, ok := strings.Cut(string(raw), "/")
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCursor, cursor)
+	}
+
+	var key pageKey
+	ts, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	key.createdAt = time.UnixMicro(ts)
+	key.id, err = uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+
+	return &key, nil
+}
+
+// FindPage returns the issues following after in (created_at, id) order.
+// Pass the Next cursor of a page to get the following one.
+func (repo *IssueRepository) FindPage(ctx context.Context, after Cursor, opts ...FindOption) (*Page, error) {
+	page, err := repo.findPage(ctx, nil, after, newFindOptions(opts))
+	return page, classify("FindPage", err)
+}
+
+// FindPageByAuthor is FindPage for the issues of one author.
+func (repo *IssueRepository) FindPageByAuthor(
+	ctx context.Context,
+	author string,
+	after Cursor,
+	opts ...FindOption,
+) (*Page, error) {
+	page, err := repo.findPage(ctx, &author, after, newFindOptions(opts))
+	return page, classify("FindPageByAuthor", err)
+}
+
+// Stream yields every issue in (created_at, id) order. Rows are read a
+// page at a time and handed over as they arrive, so memory use does not
+// grow with the table. Iteration stops at the first error.
+func (repo *IssueRepository) Stream(ctx context.Context, opts ...FindOption) iter.Seq2[Issue, error] {
+	return repo.stream(ctx, "Stream", nil, newFindOptions(opts))
+}
+
+// StreamByAuthor is Stream for the issues of one author.
+func (repo *IssueRepository) StreamByAuthor(
+	ctx context.Context,
+	author string,
+	opts ...FindOption,
+) iter.Seq2[Issue, error] {
+	return repo.stream(ctx, "StreamByAuthor", &author, newFindOptions(opts))
+}
+
+func (repo *IssueRepository) findPage(
+	ctx context.Context,
+	author *string,
+	after Cursor,
+	options findOptions,
+) (*Page, error) {
+	start, err := parseCursor(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var page = &Page{Issues: make([]Issue, 0, options.pageSize)}
+	var more = false
+
+	// One extra row tells whether another page follows.
+	_, err = repo.readPage(ctx, author, start, options.pageSize+1, options, func(issue Issue) bool {
+		if len(page.Issues) == options.pageSize {
+			more = true
+			return false
+		}
+		page.Issues = append(page.Issues, issue)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if more {
+		page.Next = keyOf(&page.Issues[len(page.Issues)-1]).cursor()
+	}
+	return page, nil
+}
+
+func (repo *IssueRepository) stream(
+	ctx context.Context,
+	op string,
+	author *string,
+	options findOptions,
+) iter.Seq2[Issue, error] {
+	return func(yield func(Issue, error) bool) {
+		var start *pageKey
+		for {
+			var stopped = false
+			last, err := repo.readPage(ctx, author, start, options.pageSize, options, func(issue Issue) bool {
+				stopped = !yield(issue, nil)
+				return !stopped
+			})
+			if err != nil {
+				yield(Issue{}, classify(op, err))
+				return
+			}
+			if stopped || last == nil {
+				return
+			}
+			start = last
+		}
+	}
+}
+
+// firstPageQuery and nextPageQuery read createdAtIndex in key order; the
+// continuation starts with a plain range on created_at so that the scan
+// begins at the cursor instead of the start of the index.
+const (
+	firstPageQuery = `
+		DECLARE $include_deleted AS Bool;
+		DECLARE $author AS Optional<Text>;
+		DECLARE $limit AS Uint64;
+
+		SELECT
+			id,
+			title,
+			created_at,
+			author,
+			COALESCE(links_count, 0) AS links_count,
+			status,
+			status_changed_at,
+			status_changed_by,
+			deleted_at
+		FROM issues VIEW createdAtIndex
+		WHERE ($include_deleted OR deleted_at IS NULL)
+			AND ($author IS NULL OR author = $author)
+		ORDER BY created_at, id
+		LIMIT $limit;
+		`
+
+	nextPageQuery = `
+		DECLARE $include_deleted AS Bool;
+		DECLARE $author AS Optional<Text>;
+		DECLARE $limit AS Uint64;
+		DECLARE $after_created_at AS Timestamp;
+		DECLARE $after_id AS Uuid;
+
+		SELECT
+			id,
+			title,
+			created_at,
+			author,
+			COALESCE(links_count, 0) AS links_count,
+			status,
+			status_changed_at,
+			status_changed_by,
+			deleted_at
+		FROM issues VIEW createdAtIndex
+		WHERE created_at >= $after_created_at
+			AND (created_at > $after_created_at OR id > $after_id)
+			AND ($include_deleted OR deleted_at IS NULL)
+			AND ($author IS NULL OR author = $author)
+		ORDER BY created_at, id
+		LIMIT $limit;
+		`
+)
+
+// readPage passes up to limit issues following start to emit and returns
+// the key of the last one when the page was full, nil otherwise. A retried
+// query skips the rows emit already got, so none is seen twice.
+func (repo *IssueRepository) readPage(
+	ctx context.Context,
+	author *string,
+	start *pageKey,
+	limit int,
+	options findOptions,
+	emit func(Issue) bool,
+) (*pageKey, error) {
+	var last *pageKey
+	var count = 0
+
+	var authorValue = types.NullValue(types.TypeText)
+	if author != nil {
+		authorValue = types.OptionalValue(types.TextValue(*author))
+	}
+
+	var yql = firstPageQuery
+	var queryParams = ydb.ParamsBuilder().
+		Param("$include_deleted").Bool(options.includeDeleted).
+		Param("$author").Any(authorValue).
+		Param("$limit").Uint64(uint64(limit))
+	if start != nil {
+		yql = nextPageQuery
+		queryParams = queryParams.
+			Param("$after_created_at").Timestamp(start.createdAt).
+			Param("$after_id").Uuid(start.id)
+	}
+
+	var err = repo.helper.Query(ctx, yql,
+		ydbQuery.SnapshotReadOnlyTxControl(),
+		queryParams.Build(),
+		func(rs ydbQuery.ResultSet, ctx context.Context) error {
+			var index = 0
+			for row, err := range sugar.UnmarshalRows[issueRow](rs.Rows(ctx)) {
+				if err != nil {
+					return err
+				}
+				var issue = row.issue()
+
+				index++
+				if index <= count {
+					continue
+				}
+				var key = keyOf(&issue)
+				last = &key
+				count++
+
+				if !emit(issue) {
+					return errStopped
+				}
+			}
+			return nil
+		},
+	)
+	if errors.Is(err, errStopped) {
+		return last, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if count < limit {
+		return nil, nil
+	}
+	return last, nil
+}
@@ -0,0 +1,80 @@
+package issue
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Status string
+
+const (
+	// StatusNone is the status of an issue that never had one assigned.
+	StatusNone       Status = ""
+	StatusNew        Status = "NEW"
+	StatusOpen       Status = "OPEN"
+	StatusInProgress Status = "IN_PROGRESS"
+	StatusFuture     Status = "FUTURE"
+	StatusClosed     Status = "CLOSED"
+)
+
+var knownStatuses = []Status{
+	StatusNew,
+	StatusOpen,
+	StatusInProgress,
+	StatusFuture,
+	StatusClosed,
+}
+
+func ParseStatus(value string) (Status, error) {
+	var status = Status(value)
+	if !slices.Contains(knownStatuses, status) {
+		return StatusNone, fmt.Errorf("%w: %q", ErrInvalidStatus, value)
+	}
+	return status, nil
+}
+
+// Transitions lists for every status the statuses it may move to.
+// Setting the current status again is always allowed and changes nothing.
+type Transitions map[Status][]Status
+
+func DefaultTransitions() Transitions {
+	return Transitions{
+		StatusNone:       {StatusNew, StatusOpen, StatusFuture},
+		StatusNew:        {StatusOpen, StatusInProgress, StatusFuture, StatusClosed},
+		StatusOpen:       {StatusInProgress, StatusFuture, StatusClosed},
+		StatusInProgress: {StatusOpen, StatusFuture, StatusClosed},
+		StatusFuture:     {StatusNew},
+		StatusClosed:     {StatusOpen},
+	}
+}
+
+func (t Transitions) Allowed(from Status, to Status) bool {
+	return from == to || slices.Contains(t[from], to)
+}
+
+func (t Transitions) check(from Status, to Status) error {
+	if !t.Allowed(from, to) {
+		return fmt.Errorf("%w: transition %q -> %q is not allowed", ErrInvalidStatus, from, to)
+	}
+	return nil
+}
+
+type StatusChange struct {
+	Id        uuid.UUID
+	From      Status
+	To        Status
+	Actor     string
+	ChangedAt time.Time
+}
+
+// Changed reports whether the update actually moved the issue to another status.
+func (c StatusChange) Changed() bool {
+	return c.From != c.To
+}
+
+type issueStatus struct {
+	Status string `sql:"status"`
+}
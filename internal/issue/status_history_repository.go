@@ -0,0 +1,112 @@
+package issue
+
+import (
+	"context"
+	"time"
+	"ydb-sample/internal/query"
+	"ydb-sample/internal/utils"
+
+	"github.com/google/uuid"
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+	ydbQuery "github.com/ydb-platform/ydb-go-sdk/v3/query"
+	"github.com/ydb-platform/ydb-go-sdk/v3/table/types"
+)
+
+type StatusHistoryEntry struct {
+	IssueId   uuid.UUID `sql:"issue_id"`
+	ChangedAt time.Time `sql:"changed_at"`
+	EventId   uuid.UUID `sql:"event_id"`
+	OldStatus string    `sql:"old_status"`
+	NewStatus string    `sql:"new_status"`
+	Actor     string    `sql:"actor"`
+}
+
+// StatusHistoryRepository keeps issue_status_history, the log of status
+// changes built from task_status events.
+type StatusHistoryRepository struct {
+	helper *query.QueryHelper
+}
+
+func NewStatusHistoryRepository(helper *query.QueryHelper) *StatusHistoryRepository {
+	return &StatusHistoryRepository{
+		helper: helper,
+	}
+}
+
+// AppendInTx writes entries inside tx. The key includes the event id, so
+// writing an event twice leaves a single row. Errors are returned
+// unclassified, like UpdateStatusInTx.
+func (repo *StatusHistoryRepository) AppendInTx(
+	ctx context.Context,
+	tx ydbQuery.TxActor,
+	entries []StatusHistoryEntry,
+) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var queryParams = ydb.ParamsBuilder().
+		Param("$entries").
+		BeginList().
+		AddItems(
+			utils.Mapped(&entries, func(i int, entry StatusHistoryEntry) types.Value {
+				return types.StructValue(
+					types.StructFieldValue("issue_id", types.UuidValue(entry.IssueId)),
+					types.StructFieldValue("changed_at", types.TimestampValueFromTime(entry.ChangedAt)),
+					types.StructFieldValue("event_id", types.UuidValue(entry.EventId)),
+					types.StructFieldValue("old_status", types.TextValue(entry.OldStatus)),
+					types.StructFieldValue("new_status", types.TextValue(entry.NewStatus)),
+					types.StructFieldValue("actor", types.TextValue(entry.Actor)),
+				)
+			})...,
+		).
+		EndList().
+		Build()
+
+	return tx.Exec(
+		ctx,
+		`
+		DECLARE $entries AS List<Struct<
+			issue_id: Uuid,
+			changed_at: Timestamp,
+			event_id: Uuid,
+			old_status: Text,
+			new_status: Text,
+			actor: Text,
+		>>;
+
+		UPSERT INTO issue_status_history
+		SELECT * FROM AS_TABLE($entries);
+		`,
+		ydbQuery.WithParameters(queryParams),
+	)
+}
+
+func (repo *StatusHistoryRepository) FindByIssue(
+	ctx context.Context,
+	id uuid.UUID,
+) ([]StatusHistoryEntry, error) {
+	var result = make([]StatusHistoryEntry, 0)
+
+	var err = repo.helper.Query(ctx, `
+		DECLARE $issue_id AS Uuid;
+
+		SELECT issue_id, changed_at, event_id, old_status, new_status, actor
+		FROM issue_status_history
+		WHERE issue_id = $issue_id
+		ORDER BY changed_at, event_id;
+		`,
+		ydbQuery.SnapshotReadOnlyTxControl(),
+		ydb.ParamsBuilder().
+			Param("$issue_id").Uuid(id).
+			Build(),
+		func(rs ydbQuery.ResultSet, ctx context.Context) error {
+			return query.Materialize(rs, ctx, &result)
+		},
+	)
+	if err != nil {
+		return result, classify("FindStatusHistory", err)
+	}
+
+	return result, nil
+}
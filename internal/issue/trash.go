@@ -0,0 +1,294 @@
+package issue
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+	"ydb-sample/internal/query"
+	"ydb-sample/internal/utils"
+
+	"github.com/google/uuid"
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+	ydbQuery "github.com/ydb-platform/ydb-go-sdk/v3/query"
+)
+
+const purgeBatchSize = 100
+
+type FindOption func(*findOptions)
+
+type findOptions struct {
+	includeDeleted bool
+	pageSize       int
+}
+
+// IncludeDeleted makes the Find methods return trashed issues as well.
+func IncludeDeleted() FindOption {
+	return func(o *findOptions) {
+		o.includeDeleted = true
+	}
+}
+
+func newFindOptions(opts []FindOption) findOptions {
+	var options = findOptions{pageSize: defaultPageSize}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.pageSize <= 0 {
+		options.pageSize = defaultPageSize
+	}
+	return options
+}
+
+// Deleted reports whether the issue is in the trash.
+func (i Issue) Deleted() bool {
+	return !i.DeletedAt.IsZero()
+}
+
+type trashRow struct {
+	Id        uuid.UUID `sql:"id"`
+	DeletedAt time.Time `sql:"deleted_at"`
+}
+
+// SoftDelete moves an issue to the trash. Its links are kept, but the
+// linked issues stop counting them until it is restored. Trashing an
+// issue twice changes nothing.
+func (repo *IssueRepository) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	var err = repo.helper.ExecuteInTx(
+		ctx,
+		func(ctx context.Context, tx ydbQuery.TxActor) error {
+			deleted, err := isTrashed(ctx, tx, id)
+			if err != nil || deleted {
+				return err
+			}
+
+			err = shiftNeighbourCounts(ctx, tx, id, false)
+			if err != nil {
+				return err
+			}
+
+			return tx.Exec(
+				ctx,
+				`
+				DECLARE $id AS Uuid;
+				DECLARE $deleted_at AS Timestamp;
+
+				UPDATE issues
+				SET deleted_at = $deleted_at
+				WHERE id = $id;
+				`,
+				ydbQuery.WithParameters(
+					ydb.ParamsBuilder().
+						Param("$id").Uuid(id).
+						Param("$deleted_at").Timestamp(time.Now()).
+						Build(),
+				),
+			)
+		},
+	)
+
+	return classify("SoftDelete", err)
+}
+
+// Restore takes an issue out of the trash and counts its links on the
+// linked issues again. Restoring a live issue changes nothing.
+func (repo *IssueRepository) Restore(ctx context.Context, id uuid.UUID) error {
+	var err = repo.helper.ExecuteInTx(
+		ctx,
+		func(ctx context.Context, tx ydbQuery.TxActor) error {
+			deleted, err := isTrashed(ctx, tx, id)
+			if err != nil || !deleted {
+				return err
+			}
+
+			err = shiftNeighbourCounts(ctx, tx, id, true)
+			if err != nil {
+				return err
+			}
+
+			return tx.Exec(
+				ctx,
+				`
+				DECLARE $id AS Uuid;
+
+				UPDATE issues
+				SET deleted_at = NULL
+				WHERE id = $id;
+				`,
+				ydbQuery.WithParameters(
+					ydb.ParamsBuilder().
+						Param("$id").Uuid(id).
+						Build(),
+				),
+			)
+		},
+	)
+
+	return classify("Restore", err)
+}
+
+// PurgeDeleted deletes the issues that were trashed more than olderThan
+// ago together with their links and returns how many were deleted. Each
+// batch is deleted in its own transaction.
+func (repo *IssueRepository) PurgeDeleted(ctx context.Context, olderThan time.Duration) (int, error) {
+	var found = make([]idRow, 0)
+
+	var err = repo.helper.Query(ctx, `
+		DECLARE $before AS Timestamp;
+
+		SELECT id FROM issues
+		WHERE deleted_at IS NOT NULL AND deleted_at < $before;
+		`,
+		ydbQuery.SnapshotReadOnlyTxControl(),
+		ydb.ParamsBuilder().
+			Param("$before").Timestamp(time.Now().Add(-olderThan)).
+			Build(),
+		func(rs ydbQuery.ResultSet, ctx context.Context) error {
+			return query.Materialize(rs, ctx, &found)
+		},
+	)
+	if err != nil {
+		return 0, classify("PurgeDeleted", err)
+	}
+
+	var ids = utils.Mapped(&found, func(i int, row idRow) uuid.UUID {
+		return row.Id
+	})
+
+	var purged = 0
+	for batch := range slices.Chunk(ids, purgeBatchSize) {
+		err = repo.helper.ExecuteInTx(
+			ctx,
+			func(ctx context.Context, tx ydbQuery.TxActor) error {
+				return deleteIssues(ctx, tx, batch, DeleteCascade)
+			},
+		)
+		if err != nil {
+			return purged, classify("PurgeDeleted", err)
+		}
+		purged += len(batch)
+	}
+
+	return purged, nil
+}
+
+// isTrashed returns ErrIssueNotFound for a missing issue.
+func isTrashed(ctx context.Context, tx ydbQuery.TxActor, id uuid.UUID) (bool, error) {
+	rows, err := tx.QueryResultSet(
+		ctx,
+		`
+		DECLARE $id AS Uuid;
+
+		SELECT id, deleted_at FROM issues
+		WHERE id = $id;
+		`,
+		ydbQuery.WithParameters(
+			ydb.ParamsBuilder().
+				Param("$id").Uuid(id).
+				Build(),
+		),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	var found = make([]trashRow, 0)
+	err = query.Materialize(rows, ctx, &found)
+	if err != nil {
+		return false, err
+	}
+	if len(found) == 0 {
+		return false, fmt.Errorf("%w: %s", ErrIssueNotFound, id)
+	}
+
+	return !found[0].DeletedAt.IsZero(), nil
+}
+
+// liveIds returns which of ids exist and are not trashed.
+func liveIds(ctx context.Context, tx ydbQuery.TxActor, id1 uuid.UUID, id2 uuid.UUID) (map[uuid.UUID]bool, error) {
+	rows, err := tx.QueryResultSet(
+		ctx,
+		`
+		DECLARE $t1 AS Uuid;
+		DECLARE $t2 AS Uuid;
+
+		SELECT id FROM issues
+		WHERE id IN ($t1, $t2) AND deleted_at IS NULL;
+		`,
+		ydbQuery.WithParameters(
+			ydb.ParamsBuilder().
+				Param("$t1").Uuid(id1).
+				Param("$t2").Uuid(id2).
+				Build(),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var found = make([]idRow, 0)
+	err = query.Materialize(rows, ctx, &found)
+	if err != nil {
+		return nil, err
+	}
+
+	var live = make(map[uuid.UUID]bool, len(found))
+	for _, row := range found {
+		live[row.Id] = true
+	}
+	return live, nil
+}
+
+// shiftNeighbourCounts takes the links to id off the counters of the
+// issues linking to it, or puts them back when restore is set. Like
+// deleteIssues it uses the neighbours' own rows of the links.
+func shiftNeighbourCounts(ctx context.Context, tx ydbQuery.TxActor, id uuid.UUID, restore bool) error {
+	return tx.Exec(
+		ctx,
+		`
+		DECLARE $id AS Uuid;
+		DECLARE $restore AS Bool;
+
+		$neighbour_links =
+			SELECT source AS issue_id, kind, COUNT(*) AS cnt
+			FROM links
+			WHERE destination = $id
+			GROUP BY source, kind;
+
+		$neighbour_totals =
+			SELECT issue_id, SUM(cnt) AS cnt
+			FROM $neighbour_links
+			GROUP BY issue_id;
+
+		$shift = ($current, $cnt) -> {
+			RETURN IF(
+				$restore,
+				$current + $cnt,
+				IF($current > $cnt, $current - $cnt, 0ul)
+			);
+		};
+
+		UPDATE issues ON
+		SELECT
+			i.id AS id,
+			$shift(COALESCE(i.links_count, 0ul), t.cnt) AS links_count
+		FROM $neighbour_totals AS t
+		JOIN issues AS i ON i.id = t.issue_id;
+
+		UPSERT INTO link_counts
+		SELECT
+			n.issue_id AS issue_id,
+			n.kind AS kind,
+			$shift(COALESCE(c.links_count, 0ul), n.cnt) AS links_count
+		FROM $neighbour_links AS n
+		JOIN issues AS i ON i.id = n.issue_id
+		LEFT JOIN link_counts AS c ON c.issue_id = n.issue_id AND c.kind = n.kind;
+		`,
+		ydbQuery.WithParameters(
+			ydb.ParamsBuilder().
+				Param("$id").Uuid(id).
+				Param("$restore").Bool(restore).
+				Build(),
+		),
+	)
+}
@@ -0,0 +1,15 @@
+package notification
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Notification struct {
+	EventId   uuid.UUID `sql:"event_id"`
+	IssueId   uuid.UUID `sql:"issue_id"`
+	OldStatus string    `sql:"old_status"`
+	NewStatus string    `sql:"new_status"`
+	QueuedAt  time.Time `sql:"queued_at"`
+}
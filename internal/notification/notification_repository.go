@@ -0,0 +1,70 @@
+package notification
+
+import (
+	"context"
+	"ydb-sample/internal/query"
+
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+	ydbQuery "github.com/ydb-platform/ydb-go-sdk/v3/query"
+)
+
+// NotificationRepository keeps the queue of status e-mails to send.
+type NotificationRepository struct {
+	helper *query.QueryHelper
+}
+
+func NewNotificationRepository(helper *query.QueryHelper) *NotificationRepository {
+	return &NotificationRepository{
+		helper: helper,
+	}
+}
+
+func (repo *NotificationRepository) Enqueue(
+	ctx context.Context,
+	tx ydbQuery.TxActor,
+	notification Notification,
+) error {
+	return tx.Exec(
+		ctx,
+		`
+		DECLARE $event_id AS Uuid;
+		DECLARE $issue_id AS Uuid;
+		DECLARE $old_status AS Text;
+		DECLARE $new_status AS Text;
+		DECLARE $queued_at AS Timestamp;
+
+		UPSERT INTO email_notifications (event_id, issue_id, old_status, new_status, queued_at)
+		VALUES ($event_id, $issue_id, $old_status, $new_status, $queued_at);
+		`,
+		ydbQuery.WithParameters(
+			ydb.ParamsBuilder().
+				Param("$event_id").Uuid(notification.EventId).
+				Param("$issue_id").Uuid(notification.IssueId).
+				Param("$old_status").Text(notification.OldStatus).
+				Param("$new_status").Text(notification.NewStatus).
+				Param("$queued_at").Timestamp(notification.QueuedAt).
+				Build(),
+		),
+	)
+}
+
+func (repo *NotificationRepository) FindAll(ctx context.Context) ([]Notification, error) {
+	var result = make([]Notification, 0)
+
+	var err = repo.helper.Query(ctx, `
+		SELECT event_id, issue_id, old_status, new_status, queued_at
+		FROM email_notifications
+		ORDER BY queued_at;
+		`,
+		ydbQuery.SnapshotReadOnlyTxControl(),
+		ydb.ParamsBuilder().Build(),
+		func(rs ydbQuery.ResultSet, ctx context.Context) error {
+			return query.Materialize(rs, ctx, &result)
+		},
+	)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,17 @@
+package outbox
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Message struct {
+	Id        uuid.UUID `sql:"id"`
+	CreatedAt time.Time `sql:"created_at"`
+	IssueId   uuid.UUID `sql:"issue_id"`
+	Payload   []byte    `sql:"payload"`
+
+	ContentType   string `sql:"content_type"`
+	SchemaVersion uint32 `sql:"schema_version"`
+}
@@ -0,0 +1,114 @@
+package outbox
+
+import (
+	"context"
+	"ydb-sample/internal/query"
+	"ydb-sample/internal/utils"
+
+	"github.com/google/uuid"
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+	ydbQuery "github.com/ydb-platform/ydb-go-sdk/v3/query"
+	"github.com/ydb-platform/ydb-go-sdk/v3/table/types"
+)
+
+// OutboxRepository stores messages that must reach a topic. Messages are
+// enqueued inside the caller's transaction and published later by a relay.
+type OutboxRepository struct {
+	helper *query.QueryHelper
+}
+
+func NewOutboxRepository(helper *query.QueryHelper) *OutboxRepository {
+	return &OutboxRepository{
+		helper: helper,
+	}
+}
+
+func (repo *OutboxRepository) Enqueue(
+	ctx context.Context,
+	tx ydbQuery.TxActor,
+	message Message,
+) error {
+	return tx.Exec(
+		ctx,
+		`
+		DECLARE $id AS Uuid;
+		DECLARE $created_at AS Timestamp;
+		DECLARE $issue_id AS Uuid;
+		DECLARE $payload AS String;
+		DECLARE $content_type AS Text;
+		DECLARE $schema_version AS Uint32;
+
+		INSERT INTO status_outbox (
+			id, created_at, issue_id, payload, content_type, schema_version
+		)
+		VALUES (
+			$id, $created_at, $issue_id, $payload, $content_type, $schema_version
+		);
+		`,
+		ydbQuery.WithParameters(
+			ydb.ParamsBuilder().
+				Param("$id").Uuid(message.Id).
+				Param("$created_at").Timestamp(message.CreatedAt).
+				Param("$issue_id").Uuid(message.IssueId).
+				Param("$payload").Bytes(message.Payload).
+				Param("$content_type").Text(message.ContentType).
+				Param("$schema_version").Uint32(message.SchemaVersion).
+				Build(),
+		),
+	)
+}
+
+// FindPending returns up to limit unsent messages, oldest first. Sent
+// messages are deleted, so the oldest rows of createdAtIndex are pending.
+func (repo *OutboxRepository) FindPending(
+	ctx context.Context,
+	limit uint64,
+) ([]Message, error) {
+	var result = make([]Message, 0)
+
+	var err = repo.helper.Query(ctx, `
+		DECLARE $limit AS Uint64;
+
+		SELECT id, created_at, issue_id, payload, content_type, schema_version
+		FROM status_outbox VIEW createdAtIndex
+		ORDER BY created_at, id
+		LIMIT $limit;
+		`,
+		ydbQuery.SnapshotReadOnlyTxControl(),
+		ydb.ParamsBuilder().
+			Param("$limit").Uint64(limit).
+			Build(),
+		func(rs ydbQuery.ResultSet, ctx context.Context) error {
+			return query.Materialize(rs, ctx, &result)
+		},
+	)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// DeleteSent removes messages the topic acknowledged.
+func (repo *OutboxRepository) DeleteSent(ctx context.Context, ids []uuid.UUID) error {
+	var queryParams = ydb.ParamsBuilder().
+		Param("$ids").
+		BeginList().
+		AddItems(
+			utils.Mapped(&ids, func(i int, id uuid.UUID) types.Value {
+				return types.UuidValue(id)
+			})...,
+		).
+		EndList().
+		Build()
+
+	return repo.helper.ExecuteWithParams(ctx, `
+		DECLARE $ids AS List<Uuid>;
+
+		DELETE FROM status_outbox
+		WHERE id IN $ids;
+		`,
+		ydbQuery.SerializableReadWriteTxControl(ydbQuery.CommitTx()),
+		queryParams,
+	)
+}
@@ -0,0 +1,108 @@
+package projection
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Mismatch is a projection row that differs from what the issues table
+// implies. A missing row is reported with empty Actual or Expected.
+type Mismatch struct {
+	Table    string
+	Key      string
+	Expected string
+	Actual   string
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s[%q]: expected %s, got %s", m.Table, m.Key, orNone(m.Expected), orNone(m.Actual))
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "no row"
+	}
+	return value
+}
+
+// Check compares both projections with counters computed from issues.
+// Rows whose counters are all zero are the same as absent rows.
+func Check(ctx context.Context, repo *StatsRepository) ([]Mismatch, error) {
+	expectedAuthors, err := repo.ExpectedAuthorStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	actualAuthors, err := repo.AuthorStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	expectedStatuses, err := repo.ExpectedStatusStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	actualStatuses, err := repo.StatusStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var mismatches = compare(
+		"author_stats",
+		indexNonZero(expectedAuthors, func(s AuthorStats) (string, string, bool) {
+			return s.Author, formatAuthorStats(s), !s.zero()
+		}),
+		indexNonZero(actualAuthors, func(s AuthorStats) (string, string, bool) {
+			return s.Author, formatAuthorStats(s), !s.zero()
+		}),
+	)
+	mismatches = append(mismatches, compare(
+		"status_stats",
+		indexNonZero(expectedStatuses, func(s StatusStats) (string, string, bool) {
+			return s.Status, fmt.Sprintf("total=%d", s.Total), s.Total != 0
+		}),
+		indexNonZero(actualStatuses, func(s StatusStats) (string, string, bool) {
+			return s.Status, fmt.Sprintf("total=%d", s.Total), s.Total != 0
+		}),
+	)...)
+
+	return mismatches, nil
+}
+
+func formatAuthorStats(s AuthorStats) string {
+	return fmt.Sprintf("total=%d open=%d in_progress=%d", s.Total, s.Open, s.InProgress)
+}
+
+func indexNonZero[T any](rows []T, describe func(T) (string, string, bool)) map[string]string {
+	var result = make(map[string]string, len(rows))
+	for _, row := range rows {
+		var key, value, nonZero = describe(row)
+		if nonZero {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+func compare(table string, expected map[string]string, actual map[string]string) []Mismatch {
+	var keys = slices.Sorted(maps.Keys(expected))
+	for key := range actual {
+		if _, ok := expected[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	var result = make([]Mismatch, 0)
+	for _, key := range keys {
+		if expected[key] != actual[key] {
+			result = append(result, Mismatch{
+				Table:    table,
+				Key:      key,
+				Expected: expected[key],
+				Actual:   actual[key],
+			})
+		}
+	}
+	return result
+}
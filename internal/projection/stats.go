@@ -0,0 +1,96 @@
+package projection
+
+import (
+	"maps"
+	"slices"
+	"ydb-sample/internal/changefeed"
+	"ydb-sample/internal/issue"
+)
+
+// AuthorStats counts the issues of one author. Issues without an author
+// are counted under the empty name.
+type AuthorStats struct {
+	Author     string `sql:"author"`
+	Total      int64  `sql:"total"`
+	Open       int64  `sql:"open_count"`
+	InProgress int64  `sql:"in_progress_count"`
+}
+
+func (s AuthorStats) zero() bool {
+	return s.Total == 0 && s.Open == 0 && s.InProgress == 0
+}
+
+// StatusStats counts the issues in one status; StatusNone is the empty one.
+type StatusStats struct {
+	Status string `sql:"status"`
+	Total  int64  `sql:"total"`
+}
+
+// Deltas accumulates how a run of changes moves the counters: the old image
+// of a row is subtracted and the new one added. Trashed issues are not
+// counted, so trashing one subtracts it and restoring adds it back.
+type Deltas struct {
+	authors  map[string]*AuthorStats
+	statuses map[string]*StatusStats
+}
+
+func NewDeltas() *Deltas {
+	return &Deltas{
+		authors:  make(map[string]*AuthorStats),
+		statuses: make(map[string]*StatusStats),
+	}
+}
+
+func (d *Deltas) Apply(change changefeed.IssueChange) {
+	d.add(change.OldImage, -1)
+	d.add(change.NewImage, 1)
+}
+
+func (d *Deltas) add(image *issue.Issue, sign int64) {
+	if image == nil || image.Deleted() {
+		return
+	}
+
+	var author, ok = d.authors[image.Author]
+	if !ok {
+		author = &AuthorStats{Author: image.Author}
+		d.authors[image.Author] = author
+	}
+	author.Total += sign
+	switch image.Status {
+	case issue.StatusOpen:
+		author.Open += sign
+	case issue.StatusInProgress:
+		author.InProgress += sign
+	}
+
+	var key = string(image.Status)
+	status, ok := d.statuses[key]
+	if !ok {
+		status = &StatusStats{Status: key}
+		d.statuses[key] = status
+	}
+	status.Total += sign
+}
+
+// Authors returns the non-zero author deltas ordered by author.
+func (d *Deltas) Authors() []AuthorStats {
+	var result = make([]AuthorStats, 0, len(d.authors))
+	for _, key := range slices.Sorted(maps.Keys(d.authors)) {
+		if !d.authors[key].zero() {
+			result = append(result, *d.authors[key])
+		}
+	}
+	return result
+}
+
+// Statuses returns the non-zero status deltas ordered by status.
+func (d *Deltas) Statuses() []StatusStats {
+	var result = make([]StatusStats, 0, len(d.statuses))
+	for _, key := range slices.Sorted(maps.Keys(d.statuses)) {
+		if d.statuses[key].Total != 0 {
+			result = append(result, *d.statuses[key])
+		}
+	}
+	return result
+}
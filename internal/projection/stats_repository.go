@@ -0,0 +1,197 @@
+package projection
+
+import (
+	"context"
+	"fmt"
+	"ydb-sample/internal/query"
+	"ydb-sample/internal/utils"
+
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+	ydbQuery "github.com/ydb-platform/ydb-go-sdk/v3/query"
+	"github.com/ydb-platform/ydb-go-sdk/v3/table/types"
+)
+
+// StatsRepository keeps the author_stats and status_stats read models.
+type StatsRepository struct {
+	helper *query.QueryHelper
+}
+
+func NewStatsRepository(helper *query.QueryHelper) *StatsRepository {
+	return &StatsRepository{
+		helper: helper,
+	}
+}
+
+// ApplyInTx adds deltas to the stored counters inside tx.
+func (repo *StatsRepository) ApplyInTx(
+	ctx context.Context,
+	tx ydbQuery.TxActor,
+	deltas *Deltas,
+) error {
+	var authors = deltas.Authors()
+	if len(authors) > 0 {
+		var err = tx.Exec(
+			ctx,
+			`
+			DECLARE $deltas AS List<Struct<
+				author: Text,
+				total: Int64,
+				open_count: Int64,
+				in_progress_count: Int64,
+			>>;
+
+			UPSERT INTO author_stats
+			SELECT
+				d.author AS author,
+				COALESCE(s.total, 0) + d.total AS total,
+				COALESCE(s.open_count, 0) + d.open_count AS open_count,
+				COALESCE(s.in_progress_count, 0) + d.in_progress_count AS in_progress_count
+			FROM AS_TABLE($deltas) AS d
+			LEFT JOIN author_stats AS s ON s.author = d.author;
+			`,
+			ydbQuery.WithParameters(
+				ydb.ParamsBuilder().
+					Param("$deltas").
+					BeginList().
+					AddItems(
+						utils.Mapped(&authors, func(i int, delta AuthorStats) types.Value {
+							return types.StructValue(
+								types.StructFieldValue("author", types.TextValue(delta.Author)),
+								types.StructFieldValue("total", types.Int64Value(delta.Total)),
+								types.StructFieldValue("open_count", types.Int64Value(delta.Open)),
+								types.StructFieldValue("in_progress_count", types.Int64Value(delta.InProgress)),
+							)
+						})...,
+					).
+					EndList().
+					Build(),
+			),
+		)
+		if err != nil {
+			return fmt.Errorf("apply author_stats: %w", err)
+		}
+	}
+
+	var statuses = deltas.Statuses()
+	if len(statuses) > 0 {
+		var err = tx.Exec(
+			ctx,
+			`
+			DECLARE $deltas AS List<Struct<
+				status: Text,
+				total: Int64,
+			>>;
+
+			UPSERT INTO status_stats
+			SELECT
+				d.status AS status,
+				COALESCE(s.total, 0) + d.total AS total
+			FROM AS_TABLE($deltas) AS d
+			LEFT JOIN status_stats AS s ON s.status = d.status;
+			`,
+			ydbQuery.WithParameters(
+				ydb.ParamsBuilder().
+					Param("$deltas").
+					BeginList().
+					AddItems(
+						utils.Mapped(&statuses, func(i int, delta StatusStats) types.Value {
+							return types.StructValue(
+								types.StructFieldValue("status", types.TextValue(delta.Status)),
+								types.StructFieldValue("total", types.Int64Value(delta.Total)),
+							)
+						})...,
+					).
+					EndList().
+					Build(),
+			),
+		)
+		if err != nil {
+			return fmt.Errorf("apply status_stats: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (repo *StatsRepository) AuthorStats(ctx context.Context) ([]AuthorStats, error) {
+	return repo.authorStats(ctx, `
+		SELECT author, total, open_count, in_progress_count
+		FROM author_stats
+		ORDER BY author;
+	`)
+}
+
+func (repo *StatsRepository) StatusStats(ctx context.Context) ([]StatusStats, error) {
+	return repo.statusStats(ctx, `
+		SELECT status, total
+		FROM status_stats
+		ORDER BY status;
+	`)
+}
+
+// ExpectedAuthorStats computes author_stats from the issues table.
+func (repo *StatsRepository) ExpectedAuthorStats(ctx context.Context) ([]AuthorStats, error) {
+	return repo.authorStats(ctx, `
+		SELECT
+			author,
+			CAST(COUNT(*) AS Int64) AS total,
+			CAST(COUNT_IF(status = "OPEN") AS Int64) AS open_count,
+			CAST(COUNT_IF(status = "IN_PROGRESS") AS Int64) AS in_progress_count
+		FROM issues
+		WHERE deleted_at IS NULL
+		GROUP BY COALESCE(author, "") AS author
+		ORDER BY author;
+	`)
+}
+
+// ExpectedStatusStats computes status_stats from the issues table.
+func (repo *StatsRepository) ExpectedStatusStats(ctx context.Context) ([]StatusStats, error) {
+	return repo.statusStats(ctx, `
+		SELECT status, CAST(COUNT(*) AS Int64) AS total
+		FROM issues
+		WHERE deleted_at IS NULL
+		GROUP BY COALESCE(status, "") AS status
+		ORDER BY status;
+	`)
+}
+
+// Reset empties both projections before a rebuild.
+func (repo *StatsRepository) Reset(ctx context.Context) error {
+	return repo.helper.ExecuteWithParams(ctx, `
+		DELETE FROM author_stats;
+		DELETE FROM status_stats;
+		`,
+		ydbQuery.SerializableReadWriteTxControl(ydbQuery.CommitTx()),
+		ydb.ParamsBuilder().Build(),
+	)
+}
+
+func (repo *StatsRepository) authorStats(ctx context.Context, yql string) ([]AuthorStats, error) {
+	var result = make([]AuthorStats, 0)
+
+	var err = repo.helper.Query(ctx,
+		yql,
+		ydbQuery.SnapshotReadOnlyTxControl(),
+		ydb.ParamsBuilder().Build(),
+		func(rs ydbQuery.ResultSet, ctx context.Context) error {
+			return query.Materialize(rs, ctx, &result)
+		},
+	)
+
+	return result, err
+}
+
+func (repo *StatsRepository) statusStats(ctx context.Context, yql string) ([]StatusStats, error) {
+	var result = make([]StatusStats, 0)
+
+	var err = repo.helper.Query(ctx,
+		yql,
+		ydbQuery.SnapshotReadOnlyTxControl(),
+		ydb.ParamsBuilder().Build(),
+		func(rs ydbQuery.ResultSet, ctx context.Context) error {
+			return query.Materialize(rs, ctx, &result)
+		},
+	)
+
+	return result, err
+}
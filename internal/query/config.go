@@ -0,0 +1,194 @@
+package query
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+	"github.com/ydb-platform/ydb-go-sdk/v3/balancers"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultDSN         = "grpc://localhost:2136/local"
+	defaultDialTimeout = 5 * time.Second
+)
+
+type Config struct {
+	DSN              string            `yaml:"dsn"`
+	DialTimeout      time.Duration     `yaml:"dial_timeout"`
+	SessionPoolLimit int               `yaml:"session_pool_limit"`
+	Balancer         string            `yaml:"balancer"`
+	Credentials      CredentialsConfig `yaml:"credentials"`
+	TLS              TLSConfig         `yaml:"tls"`
+}
+
+type CredentialsConfig struct {
+	// Type is one of anonymous, static, token, token_file or metadata.
+	// When empty it is derived from the fields that are set.
+	Type        string        `yaml:"type"`
+	AccessToken string        `yaml:"access_token"`
+	User        string        `yaml:"user"`
+	Password    string        `yaml:"password"`
+	TokenFile   string        `yaml:"token_file"`
+	TokenReload time.Duration `yaml:"token_reload"`
+	MetadataURL string        `yaml:"metadata_url"`
+}
+
+type TLSConfig struct {
+	// Enabled forces TLS even for a grpc:// DSN; grpcs:// enables it anyway.
+	Enabled            bool   `yaml:"enabled"`
+	CAFile             string `yaml:"ca_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		DSN:         defaultDSN,
+		DialTimeout: defaultDialTimeout,
+		Balancer:    "random_choice",
+	}
+}
+
+// LoadConfig reads the YAML file at path (skipped when path is empty) on top
+// of the defaults and then applies YDB_* environment overrides.
+func LoadConfig(path string) (Config, error) {
+	var config = DefaultConfig()
+
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return config, err
+		}
+
+		err = yaml.Unmarshal(content, &config)
+		if err != nil {
+			return config, fmt.Errorf("parse config %s: %w", path, err)
+		}
+	}
+
+	var err = config.applyEnv()
+	if err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+func (config *Config) applyEnv() error {
+	if value, ok := os.LookupEnv("YDB_DSN"); ok {
+		config.DSN = value
+	}
+	if value, ok := os.LookupEnv("YDB_DIAL_TIMEOUT"); ok {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("YDB_DIAL_TIMEOUT: %w", err)
+		}
+		config.DialTimeout = timeout
+	}
+	if value, ok := os.LookupEnv("YDB_SESSION_POOL_LIMIT"); ok {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("YDB_SESSION_POOL_LIMIT: %w", err)
+		}
+		config.SessionPoolLimit = limit
+	}
+	if value, ok := os.LookupEnv("YDB_BALANCER"); ok {
+		config.Balancer = value
+	}
+	if value, ok := os.LookupEnv("YDB_CREDENTIALS"); ok {
+		config.Credentials.Type = value
+	}
+	if value, ok := os.LookupEnv("YDB_ACCESS_TOKEN"); ok {
+		config.Credentials.AccessToken = value
+	}
+	if value, ok := os.LookupEnv("YDB_USER"); ok {
+		config.Credentials.User = value
+	}
+	if value, ok := os.LookupEnv("YDB_PASSWORD"); ok {
+		config.Credentials.Password = value
+	}
+	if value, ok := os.LookupEnv("YDB_TOKEN_FILE"); ok {
+		config.Credentials.TokenFile = value
+	}
+	if value, ok := os.LookupEnv("YDB_TOKEN_RELOAD"); ok {
+		reload, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("YDB_TOKEN_RELOAD: %w", err)
+		}
+		config.Credentials.TokenReload = reload
+	}
+	if value, ok := os.LookupEnv("YDB_METADATA_URL"); ok {
+		config.Credentials.MetadataURL = value
+	}
+	if value, ok := os.LookupEnv("YDB_TLS"); ok {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("YDB_TLS: %w", err)
+		}
+		config.TLS.Enabled = enabled
+	}
+	if value, ok := os.LookupEnv("YDB_CA_FILE"); ok {
+		config.TLS.CAFile = value
+	}
+	if value, ok := os.LookupEnv("YDB_TLS_INSECURE_SKIP_VERIFY"); ok {
+		skip, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("YDB_TLS_INSECURE_SKIP_VERIFY: %w", err)
+		}
+		config.TLS.InsecureSkipVerify = skip
+	}
+	return nil
+}
+
+func (config Config) options() ([]ydb.Option, error) {
+	var opts = make([]ydb.Option, 0)
+
+	if config.DialTimeout > 0 {
+		opts = append(opts, ydb.WithDialTimeout(config.DialTimeout))
+	}
+	if config.SessionPoolLimit > 0 {
+		opts = append(opts, ydb.WithSessionPoolSizeLimit(config.SessionPoolLimit))
+	}
+
+	balancer, err := balancerOption(config.Balancer)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, balancer)
+
+	credentials, err := config.Credentials.option()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, credentials)
+
+	if config.TLS.Enabled {
+		opts = append(opts, ydb.WithSecure(true))
+	}
+	if config.TLS.CAFile != "" {
+		opts = append(opts, ydb.WithCertificatesFromFile(config.TLS.CAFile))
+	}
+	if config.TLS.InsecureSkipVerify {
+		opts = append(opts, ydb.WithTLSSInsecureSkipVerify())
+	}
+
+	return opts, nil
+}
+
+func balancerOption(name string) (ydb.Option, error) {
+	switch name {
+	case "", "random_choice":
+		return ydb.WithBalancer(balancers.RandomChoice()), nil
+	case "single":
+		return ydb.WithBalancer(balancers.SingleConn()), nil
+	case "prefer_nearest_dc":
+		return ydb.WithBalancer(
+			balancers.PreferNearestDCWithFallBack(balancers.RandomChoice()),
+		), nil
+	default:
+		return nil, fmt.Errorf("unknown balancer %q", name)
+	}
+}
@@ -0,0 +1,210 @@
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+)
+
+const (
+	CredentialsAnonymous = "anonymous"
+	CredentialsStatic    = "static"
+	CredentialsToken     = "token"
+	CredentialsTokenFile = "token_file"
+	CredentialsMetadata  = "metadata"
+
+	defaultTokenReload = time.Minute
+	defaultMetadataURL = "http://169.254.169.254/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+func (config CredentialsConfig) option() (ydb.Option, error) {
+	switch config.kind() {
+	case CredentialsAnonymous:
+		return ydb.WithAnonymousCredentials(), nil
+	case CredentialsStatic:
+		if config.User == "" {
+			return nil, errors.New("static credentials require user")
+		}
+		return ydb.WithStaticCredentials(config.User, config.Password), nil
+	case CredentialsToken:
+		if config.AccessToken == "" {
+			return nil, errors.New("token credentials require access_token")
+		}
+		return ydb.WithAccessTokenCredentials(config.AccessToken), nil
+	case CredentialsTokenFile:
+		if config.TokenFile == "" {
+			return nil, errors.New("token_file credentials require token_file")
+		}
+		return ydb.WithCredentials(
+			newTokenFileCredentials(config.TokenFile, config.TokenReload),
+		), nil
+	case CredentialsMetadata:
+		return ydb.WithCredentials(
+			newMetadataCredentials(config.MetadataURL),
+		), nil
+	default:
+		return nil, fmt.Errorf("unknown credentials type %q", config.Type)
+	}
+}
+
+// kind falls back to guessing the provider from the filled fields, so a
+// config may omit the type when only one kind of secret is set.
+func (config CredentialsConfig) kind() string {
+	switch {
+	case config.Type != "":
+		return config.Type
+	case config.AccessToken != "":
+		return CredentialsToken
+	case config.TokenFile != "":
+		return CredentialsTokenFile
+	case config.User != "":
+		return CredentialsStatic
+	default:
+		return CredentialsAnonymous
+	}
+}
+
+// tokenFileCredentials rereads the token file once the reload interval has
+// passed and the file modification time changed, so rotated tokens are
+// picked up without reconnecting.
+type tokenFileCredentials struct {
+	path   string
+	reload time.Duration
+
+	mu        sync.Mutex
+	token     string
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func newTokenFileCredentials(path string, reload time.Duration) *tokenFileCredentials {
+	if reload <= 0 {
+		reload = defaultTokenReload
+	}
+
+	return &tokenFileCredentials{
+		path:   path,
+		reload: reload,
+	}
+}
+
+func (c *tokenFileCredentials) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Since(c.checkedAt) < c.reload {
+		return c.token, nil
+	}
+
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return "", err
+	}
+	c.checkedAt = time.Now()
+
+	if c.token != "" && info.ModTime().Equal(c.modTime) {
+		return c.token, nil
+	}
+
+	content, err := os.ReadFile(c.path)
+	if err != nil {
+		return "", err
+	}
+
+	var token = strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", c.path)
+	}
+
+	c.token = token
+	c.modTime = info.ModTime()
+
+	return c.token, nil
+}
+
+func (c *tokenFileCredentials) String() string {
+	return fmt.Sprintf("TokenFile{path:%q}", c.path)
+}
+
+// metadataCredentials fetches an IAM token from a cloud instance metadata
+// endpoint (GCE-style JSON with access_token and expires_in) and caches it
+// until shortly before expiry.
+type metadataCredentials struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func newMetadataCredentials(url string) *metadataCredentials {
+	if url == "" {
+		url = defaultMetadataURL
+	}
+
+	return &metadataCredentials{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (c *metadataCredentials) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.expiresAt) {
+		return c.token, nil
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Metadata-Flavor", "Google")
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata endpoint %s: %s", c.url, response.Status)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&body)
+	if err != nil {
+		return "", err
+	}
+	if body.AccessToken == "" {
+		return "", fmt.Errorf("metadata endpoint %s returned no token", c.url)
+	}
+
+	// Refresh a bit earlier than the real expiry to avoid racing it.
+	var ttl = time.Duration(body.ExpiresIn)*time.Second - 30*time.Second
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	c.token = body.AccessToken
+	c.expiresAt = time.Now().Add(ttl)
+
+	return c.token, nil
+}
+
+func (c *metadataCredentials) String() string {
+	return fmt.Sprintf("Metadata{url:%q}", c.url)
+}
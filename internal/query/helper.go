@@ -3,9 +3,12 @@ package query
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
-	"log"
 
+	"github.com/ydb-platform/ydb-go-genproto/Ydb_Topic_V1"
+	"github.com/ydb-platform/ydb-go-genproto/protos/Ydb"
+	"github.com/ydb-platform/ydb-go-genproto/protos/Ydb_Topic"
 	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
 	"github.com/ydb-platform/ydb-go-sdk/v3/query"
 	"github.com/ydb-platform/ydb-go-sdk/v3/table"
@@ -17,23 +20,27 @@ import (
 
 type QueryHelper struct {
 	driver *ydb.Driver
-	ctx    context.Context
 }
 
-func NewQueryHelper(ctx context.Context, dsn string) *QueryHelper {
-	db, err := ydb.Open(ctx, dsn)
+func NewQueryHelper(ctx context.Context, config Config) (*QueryHelper, error) {
+	opts, err := config.options()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+
+	db, err := ydb.Open(ctx, config.DSN, opts...)
+	if err != nil {
+		return nil, err
 	}
 
 	return &QueryHelper{
 		driver: db,
-		ctx:    ctx,
-	}
+	}, nil
 }
 
-func (helper *QueryHelper) Execute(yql string) error {
+func (helper *QueryHelper) Execute(ctx context.Context, yql string) error {
 	return helper.ExecuteWithParams(
+		ctx,
 		yql,
 		query.NoTx(),
 		ydb.ParamsBuilder().Build(),
@@ -41,12 +48,13 @@ func (helper *QueryHelper) Execute(yql string) error {
 }
 
 func (helper *QueryHelper) ExecuteWithParams(
+	ctx context.Context,
 	yql string,
 	txControl *query.TransactionControl,
 	params ydb.Params,
 ) error {
 	return helper.driver.Query().Do(
-		helper.ctx,
+		ctx,
 		func(ctx context.Context, s query.Session) error {
 			err := s.Exec(
 				ctx,
@@ -60,10 +68,11 @@ func (helper *QueryHelper) ExecuteWithParams(
 }
 
 func (helper *QueryHelper) ExecuteInTx(
+	ctx context.Context,
 	execute func(context.Context, query.TxActor) error,
 ) error {
 	return helper.driver.Query().DoTx(
-		helper.ctx,
+		ctx,
 		func(ctx context.Context, tx query.TxActor) error {
 			return execute(ctx, tx)
 		},
@@ -71,13 +80,14 @@ func (helper *QueryHelper) ExecuteInTx(
 }
 
 func (helper *QueryHelper) Query(
+	ctx context.Context,
 	yql string,
 	txControl *query.TransactionControl,
 	params ydb.Params,
 	materializeResult func(query.ResultSet, context.Context) error,
 ) error {
 	return helper.driver.Query().Do(
-		helper.ctx,
+		ctx,
 		func(ctx context.Context, s query.Session) error {
 			result, err := s.Query(
 				ctx,
@@ -102,7 +112,7 @@ func (helper *QueryHelper) Query(
 					return err
 				}
 
-				err = materializeResult(resultSet, helper.ctx)
+				err = materializeResult(resultSet, ctx)
 				if err != nil {
 					return err
 				}
@@ -113,32 +123,72 @@ func (helper *QueryHelper) Query(
 	)
 }
 
-func (helper *QueryHelper) Close() {
-	helper.driver.Close(helper.ctx)
+func (helper *QueryHelper) Close(ctx context.Context) error {
+	return helper.driver.Close(ctx)
 }
 
 func (helper *QueryHelper) Topic() topic.Client {
 	return helper.driver.Topic()
 }
 
+// CommitTopicOffset sets the committed offset of consumer in one partition,
+// backwards as well as forwards. The SDK readers only commit what they
+// have read, so this goes to the topic service directly.
+func (helper *QueryHelper) CommitTopicOffset(
+	ctx context.Context,
+	path string,
+	partitionId int64,
+	consumer string,
+	offset int64,
+) error {
+	var client = Ydb_Topic_V1.NewTopicServiceClient(ydb.GRPCConn(helper.driver))
+
+	response, err := client.CommitOffset(ctx, &Ydb_Topic.CommitOffsetRequest{
+		Path:        path,
+		PartitionId: partitionId,
+		Consumer:    consumer,
+		Offset:      offset,
+	})
+	if err != nil {
+		return err
+	}
+
+	var operation = response.GetOperation()
+	if operation.GetStatus() != Ydb.StatusIds_SUCCESS {
+		return fmt.Errorf(
+			"commit offset %d of %s/%d for %s: %s: %v",
+			offset,
+			path,
+			partitionId,
+			consumer,
+			operation.GetStatus(),
+			operation.GetIssues(),
+		)
+	}
+
+	return nil
+}
+
 func (helper *QueryHelper) BulkUpsert(
+	ctx context.Context,
 	table string,
 	data table.BulkUpsertData,
 ) error {
 	return helper.driver.Table().BulkUpsert(
-		helper.ctx,
+		ctx,
 		table,
 		data,
 	)
 }
 
 func (helper *QueryHelper) ReadTable(
+	ctx context.Context,
 	tableName string,
 	materializeResult func(result.StreamResult, context.Context) error,
 	opts ...options.ReadTableOption,
 ) error {
 	return helper.driver.Table().Do(
-		helper.ctx,
+		ctx,
 		func(ctx context.Context, s table.Session) error {
 			result, err := s.StreamReadTable(ctx, tableName, opts...)
 			if err != nil {
@@ -153,12 +203,13 @@ func (helper *QueryHelper) ReadTable(
 }
 
 func (helper *QueryHelper) ReadRows(
+	ctx context.Context,
 	tableName string,
 	keys types.Value,
 	readRowOpts ...options.ReadRowsOption,
 ) (result.Result, error) {
 	return helper.driver.Table().ReadRows(
-		helper.ctx,
+		ctx,
 		tableName,
 		keys,
 		readRowOpts,
@@ -1,6 +1,7 @@
 package schema
 
 import (
+	"context"
 	"log"
 	"ydb-sample/internal/query"
 )
@@ -15,8 +16,8 @@ func NewSchemaRepository(query *query.QueryHelper) *SchemaRepository {
 	}
 }
 
-func (repo *SchemaRepository) CreateSchema() {
-	err := repo.query.Execute(`
+func (repo *SchemaRepository) CreateSchema(ctx context.Context) {
+	err := repo.query.Execute(ctx, `
 		CREATE TABLE IF NOT EXISTS issues (
 			id Uuid NOT NULL,
 			title Text NOT NULL,
@@ -29,22 +30,31 @@ func (repo *SchemaRepository) CreateSchema() {
 		log.Fatal(err)
 	}
 
-	err = repo.query.Execute(`
+	err = repo.query.Execute(ctx, `
 		ALTER TABLE issues ADD COLUMN links_count Uint64;
 
 		CREATE TABLE IF NOT EXISTS links (
 			source Uuid NOT NULL,
 			destination Uuid NOT NULL,
-			PRIMARY KEY (source, destination)
+			kind Text NOT NULL,
+			PRIMARY KEY (source, destination, kind)
+		);
+
+		CREATE TABLE IF NOT EXISTS link_counts (
+			issue_id Uuid NOT NULL,
+			kind Text NOT NULL,
+			links_count Uint64 NOT NULL,
+			PRIMARY KEY (issue_id, kind)
 		);
 	`)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = repo.query.Execute(`
+	err = repo.query.Execute(ctx, `
 		CREATE TOPIC IF NOT EXISTS task_status(
-			CONSUMER email
+			CONSUMER email,
+			CONSUMER history
 		) WITH(
 			auto_partitioning_strategy = 'scale_up',
 			min_active_partitions = 2,
@@ -52,13 +62,87 @@ func (repo *SchemaRepository) CreateSchema() {
 			retention_period = INTERVAL('P3D')
 		);
 
+		CREATE TOPIC IF NOT EXISTS task_status_dlq(
+			CONSUMER inspect,
+			CONSUMER redrive
+		) WITH(
+			retention_period = INTERVAL('P7D')
+		);
+
 		ALTER TABLE issues ADD COLUMN status Text;
+		ALTER TABLE issues ADD COLUMN status_changed_at Timestamp;
+		ALTER TABLE issues ADD COLUMN status_changed_by Text;
+		ALTER TABLE issues ADD COLUMN deleted_at Timestamp;
+
+		CREATE TABLE IF NOT EXISTS status_outbox (
+			id Uuid NOT NULL,
+			created_at Timestamp NOT NULL,
+			issue_id Uuid NOT NULL,
+			payload String NOT NULL,
+			content_type Text NOT NULL,
+			schema_version Uint32 NOT NULL,
+			PRIMARY KEY (id),
+			INDEX createdAtIndex GLOBAL ON (created_at)
+		);
+
+		CREATE TABLE IF NOT EXISTS processed_events (
+			consumer Text NOT NULL,
+			event_id Text NOT NULL,
+			processed_at Timestamp NOT NULL,
+			PRIMARY KEY (consumer, event_id)
+		) WITH (
+			TTL = Interval("P7D") ON processed_at
+		);
+
+		CREATE TABLE IF NOT EXISTS issue_status_history (
+			issue_id Uuid NOT NULL,
+			changed_at Timestamp NOT NULL,
+			event_id Uuid NOT NULL,
+			old_status Text NOT NULL,
+			new_status Text NOT NULL,
+			actor Text NOT NULL,
+			PRIMARY KEY (issue_id, changed_at, event_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS issue_history (
+			issue_id Uuid NOT NULL,
+			step Uint64 NOT NULL,
+			tx_id Uint64 NOT NULL,
+			column_name Text NOT NULL,
+			kind Text NOT NULL,
+			old_value Text NOT NULL,
+			new_value Text NOT NULL,
+			PRIMARY KEY (issue_id, step, tx_id, column_name)
+		);
+
+		CREATE TABLE IF NOT EXISTS email_notifications (
+			event_id Uuid NOT NULL,
+			issue_id Uuid NOT NULL,
+			old_status Text NOT NULL,
+			new_status Text NOT NULL,
+			queued_at Timestamp NOT NULL,
+			PRIMARY KEY (event_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS author_stats (
+			author Text NOT NULL,
+			total Int64 NOT NULL,
+			open_count Int64 NOT NULL,
+			in_progress_count Int64 NOT NULL,
+			PRIMARY KEY (author)
+		);
+
+		CREATE TABLE IF NOT EXISTS status_stats (
+			status Text NOT NULL,
+			total Int64 NOT NULL,
+			PRIMARY KEY (status)
+		);
 	`)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = repo.query.Execute(`
+	err = repo.query.Execute(ctx, `
 		ALTER TABLE issues ADD CHANGEFEED updates WITH (
 			FORMAT = 'JSON',
 			MODE = 'NEW_AND_OLD_IMAGES',
@@ -70,14 +154,49 @@ func (repo *SchemaRepository) CreateSchema() {
 		log.Fatal(err)
 	}
 
-	err = repo.query.Execute("ALTER TOPIC `issues/updates` ADD CONSUMER test;")
+	err = repo.query.Execute(ctx, "ALTER TOPIC `issues/updates` ADD CONSUMER test, ADD CONSUMER history;")
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	repo.CreateCreatedAtIndex(ctx)
+	repo.CreateProjectionsChangefeed(ctx)
 }
 
-func (repo *SchemaRepository) CreateAuthorIndex() {
-	err := repo.query.Execute(`
+// CreateProjectionsChangefeed adds issues/projections, the changefeed read
+// models are built from. It is separate from issues/updates so that a
+// rebuild can recreate it without moving other consumers; the initial scan
+// replays every existing row as an insert.
+func (repo *SchemaRepository) CreateProjectionsChangefeed(ctx context.Context) {
+	err := repo.query.Execute(ctx, `
+		ALTER TABLE issues ADD CHANGEFEED projections WITH (
+			FORMAT = 'JSON',
+			MODE = 'NEW_AND_OLD_IMAGES',
+			VIRTUAL_TIMESTAMPS = TRUE,
+			INITIAL_SCAN = TRUE
+		);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = repo.query.Execute(ctx, "ALTER TOPIC `issues/projections` ADD CONSUMER stats;")
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (repo *SchemaRepository) DropProjectionsChangefeed(ctx context.Context) {
+	err := repo.query.Execute(ctx, `
+		ALTER TABLE issues DROP CHANGEFEED projections;
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (repo *SchemaRepository) CreateAuthorIndex(ctx context.Context) {
+	err := repo.query.Execute(ctx, `
 		ALTER TABLE issues ADD INDEX authorIndex GLOBAL ON (author);
 	`)
 	if err != nil {
@@ -85,22 +204,52 @@ func (repo *SchemaRepository) CreateAuthorIndex() {
 	}
 }
 
-func (repo *SchemaRepository) DropSchema() {
-	err := repo.query.Execute(`
+// CreateCreatedAtIndex adds the (created_at, id) index issue pages are
+// read through. Like authorIndex it is synchronous, so it has to be
+// dropped around bulk upserts into issues.
+func (repo *SchemaRepository) CreateCreatedAtIndex(ctx context.Context) {
+	err := repo.query.Execute(ctx, `
+		ALTER TABLE issues ADD INDEX createdAtIndex GLOBAL ON (created_at, id);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (repo *SchemaRepository) DropSchema(ctx context.Context) {
+	err := repo.query.Execute(ctx, `
 		DROP TABLE IF EXISTS issues;
 		DROP TABLE IF EXISTS links;
+		DROP TABLE IF EXISTS link_counts;
+		DROP TABLE IF EXISTS status_outbox;
+		DROP TABLE IF EXISTS processed_events;
+		DROP TABLE IF EXISTS email_notifications;
+		DROP TABLE IF EXISTS issue_status_history;
+		DROP TABLE IF EXISTS issue_history;
+		DROP TABLE IF EXISTS author_stats;
+		DROP TABLE IF EXISTS status_stats;
 		DROP TOPIC IF EXISTS task_status;
+		DROP TOPIC IF EXISTS task_status_dlq;
 	`)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
-func (repo *SchemaRepository) DropAuthorIndex() {
-	err := repo.query.Execute(`
+func (repo *SchemaRepository) DropAuthorIndex(ctx context.Context) {
+	err := repo.query.Execute(ctx, `
 		ALTER TABLE issues DROP INDEX authorIndex;
 	`)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+func (repo *SchemaRepository) DropCreatedAtIndex(ctx context.Context) {
+	err := repo.query.Execute(ctx, `
+		ALTER TABLE issues DROP INDEX createdAtIndex;
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
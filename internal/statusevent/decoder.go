@@ -0,0 +1,17 @@
+package statusevent
+
+import (
+	"io"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicreader"
+)
+
+// DecodeMessage reads a task_status message and decodes its payload.
+func DecodeMessage(message *topicreader.Message) (StatusChanged, error) {
+	content, err := io.ReadAll(message)
+	if err != nil {
+		return StatusChanged{}, err
+	}
+
+	return Decode(content, message.Metadata)
+}
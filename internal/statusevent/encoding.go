@@ -0,0 +1,143 @@
+package statusevent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"ydb-sample/internal/statusevent/statuseventpb"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+)
+
+type Encoding string
+
+const (
+	EncodingJSON     Encoding = "application/json"
+	EncodingProtobuf Encoding = "application/x-protobuf"
+)
+
+var (
+	ErrUnknownEncoding    = errors.New("unknown event encoding")
+	ErrUnsupportedVersion = errors.New("unsupported event schema version")
+	ErrMalformedEvent     = errors.New("malformed event")
+)
+
+func ParseEncoding(value string) (Encoding, error) {
+	switch value {
+	case "json", string(EncodingJSON):
+		return EncodingJSON, nil
+	case "protobuf", string(EncodingProtobuf):
+		return EncodingProtobuf, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownEncoding, value)
+	}
+}
+
+func Encode(event StatusChanged, encoding Encoding) ([]byte, error) {
+	switch encoding {
+	case EncodingJSON:
+		return json.Marshal(event)
+	case EncodingProtobuf:
+		return marshalProtobuf(event)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownEncoding, encoding)
+	}
+}
+
+// Decode parses a payload using the content type and schema version taken
+// from the message metadata. Messages without metadata are treated as JSON
+// of the current version.
+func Decode(data []byte, metadata map[string][]byte) (StatusChanged, error) {
+	var event StatusChanged
+
+	var encoding = EncodingJSON
+	if value, ok := metadata[MetadataContentType]; ok {
+		encoding = Encoding(value)
+	}
+
+	if value, ok := metadata[MetadataSchemaVersion]; ok {
+		version, err := strconv.Atoi(string(value))
+		if err != nil || version != SchemaVersion {
+			return event, fmt.Errorf("%w: %q", ErrUnsupportedVersion, value)
+		}
+	}
+
+	switch encoding {
+	case EncodingJSON:
+		var err = json.Unmarshal(data, &event)
+		if err != nil {
+			return event, fmt.Errorf("%w: %w", ErrMalformedEvent, err)
+		}
+		return normalize(event), nil
+	case EncodingProtobuf:
+		return unmarshalProtobuf(data)
+	default:
+		return event, fmt.Errorf("%w: %q", ErrUnknownEncoding, encoding)
+	}
+}
+
+func marshalProtobuf(event StatusChanged) ([]byte, error) {
+	var message = &statuseventpb.StatusChanged{
+		EventId:   event.EventId.String(),
+		IssueId:   event.IssueId.String(),
+		OldStatus: event.OldStatus,
+		NewStatus: event.NewStatus,
+		Actor:     event.Actor,
+	}
+	if !event.Timestamp.IsZero() {
+		message.Timestamp = event.Timestamp.UnixMicro()
+	}
+	return proto.Marshal(message)
+}
+
+func unmarshalProtobuf(data []byte) (StatusChanged, error) {
+	var event StatusChanged
+
+	var message statuseventpb.StatusChanged
+	var err = proto.Unmarshal(data, &message)
+	if err != nil {
+		return event, fmt.Errorf("%w: %w", ErrMalformedEvent, err)
+	}
+
+	event.EventId, err = parseId(message.EventId)
+	if err != nil {
+		return event, err
+	}
+	event.IssueId, err = parseId(message.IssueId)
+	if err != nil {
+		return event, err
+	}
+	event.OldStatus = message.OldStatus
+	event.NewStatus = message.NewStatus
+	event.Actor = message.Actor
+	if message.Timestamp != 0 {
+		event.Timestamp = time.UnixMicro(message.Timestamp)
+	}
+
+	return normalize(event), nil
+}
+
+// parseId treats a missing id as uuid.Nil, like the JSON decoder does.
+func parseId(value string) (uuid.UUID, error) {
+	if value == "" {
+		return uuid.Nil, nil
+	}
+
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return id, fmt.Errorf("%w: %w", ErrMalformedEvent, err)
+	}
+	return id, nil
+}
+
+// normalize makes both encodings decode the same event to the same value:
+// timestamps are in UTC with the microsecond precision of protobuf.
+func normalize(event StatusChanged) StatusChanged {
+	if !event.Timestamp.IsZero() {
+		event.Timestamp = event.Timestamp.UTC().Truncate(time.Microsecond)
+	}
+	return event
+}
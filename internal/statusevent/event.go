@@ -0,0 +1,27 @@
+package statusevent
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SchemaVersion is bumped on every incompatible change of StatusChanged.
+// Decoders reject payloads with a version they do not know.
+const SchemaVersion = 1
+
+// Message metadata keys written next to every task_status payload.
+const (
+	MetadataContentType   = "content-type"
+	MetadataSchemaVersion = "schema-version"
+)
+
+// StatusChanged is the payload of a task_status topic message.
+type StatusChanged struct {
+	EventId   uuid.UUID `json:"event_id"`
+	IssueId   uuid.UUID `json:"issue_id"`
+	OldStatus string    `json:"old_status"`
+	NewStatus string    `json:"new_status"`
+	Actor     string    `json:"actor"`
+	Timestamp time.Time `json:"timestamp"`
+}
@@ -0,0 +1,4 @@
+// Package statuseventpb holds the protobuf form of task_status events.
+package statuseventpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative status_changed.proto
@@ -0,0 +1,43 @@
+package topic
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 10 * time.Second
+)
+
+// backoff doubles the delay after every failure up to maxBackoff and starts
+// over after a success.
+type backoff struct {
+	attempt int
+}
+
+func (b *backoff) next() time.Duration {
+	var delay = minBackoff << b.attempt
+	if delay <= 0 || delay > maxBackoff {
+		return maxBackoff
+	}
+	b.attempt++
+	return delay
+}
+
+func (b *backoff) reset() {
+	b.attempt = 0
+}
+
+// sleep waits for delay and reports false if ctx was cancelled first.
+func sleep(ctx context.Context, delay time.Duration) bool {
+	var timer = time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,350 @@
+package topic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicoptions"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicreader"
+)
+
+// Message is a topic message with its payload already read, so handlers
+// and middlewares may look at Data as often as they like.
+type Message struct {
+	Topic          string
+	PartitionId    int64
+	Offset         int64
+	SeqNo          int64
+	CreatedAt      time.Time
+	WrittenAt      time.Time
+	ProducerId     string
+	MessageGroupId string
+	Metadata       map[string][]byte
+	Data           []byte
+}
+
+func newMessage(raw *topicreader.Message) (*Message, error) {
+	data, err := io.ReadAll(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Topic:          raw.Topic(),
+		PartitionId:    raw.PartitionID(),
+		Offset:         raw.Offset,
+		SeqNo:          raw.SeqNo,
+		CreatedAt:      raw.CreatedAt,
+		WrittenAt:      raw.WrittenAt,
+		ProducerId:     raw.ProducerID,
+		MessageGroupId: raw.MessageGroupID,
+		Metadata:       raw.Metadata,
+		Data:           data,
+	}, nil
+}
+
+type Handler interface {
+	Handle(ctx context.Context, message *Message) error
+}
+
+type HandlerFunc func(ctx context.Context, message *Message) error
+
+func (f HandlerFunc) Handle(ctx context.Context, message *Message) error {
+	return f(ctx, message)
+}
+
+// BatchHandler receives several messages at once; they are committed
+// together after it succeeded. Messages of one partition keep their order.
+type BatchHandler interface {
+	HandleBatch(ctx context.Context, messages []*Message) error
+}
+
+type BatchHandlerFunc func(ctx context.Context, messages []*Message) error
+
+func (f BatchHandlerFunc) HandleBatch(ctx context.Context, messages []*Message) error {
+	return f(ctx, messages)
+}
+
+// CommitPolicy controls how committed offsets reach the server. Messages
+// are always committed after their handler succeeded; the policy only
+// decides whether that commit is sent right away or batched.
+type CommitPolicy struct {
+	mode     topicoptions.CommitMode
+	count    int
+	interval time.Duration
+}
+
+// CommitEveryMessage waits for the server to acknowledge each commit.
+func CommitEveryMessage() CommitPolicy {
+	return CommitPolicy{mode: topicoptions.CommitModeSync}
+}
+
+// CommitEveryBatch sends commits to the server once size of them piled up.
+func CommitEveryBatch(size int) CommitPolicy {
+	return CommitPolicy{mode: topicoptions.CommitModeAsync, count: size}
+}
+
+// CommitPeriodically sends the accumulated commits every interval.
+func CommitPeriodically(interval time.Duration) CommitPolicy {
+	return CommitPolicy{mode: topicoptions.CommitModeAsync, interval: interval}
+}
+
+func (p CommitPolicy) readerOptions() []topicoptions.ReaderOption {
+	var opts = []topicoptions.ReaderOption{
+		topicoptions.WithReaderCommitMode(p.mode),
+	}
+	if p.count > 0 {
+		opts = append(opts,
+			topicoptions.WithReaderCommitCountTrigger(p.count),
+			// Without a lag the count trigger would never get a chance.
+			topicoptions.WithReaderCommitTimeLagTrigger(time.Minute),
+		)
+	}
+	if p.interval > 0 {
+		opts = append(opts, topicoptions.WithReaderCommitTimeLagTrigger(p.interval))
+	}
+	return opts
+}
+
+const (
+	defaultBatchSize    = 100
+	defaultBatchLatency = 100 * time.Millisecond
+)
+
+type ConsumerOption func(*Consumer)
+
+// WithMiddleware wraps the handler; the first middleware is the outermost.
+func WithMiddleware(middlewares ...Middleware) ConsumerOption {
+	return func(c *Consumer) {
+		c.middlewares = append(c.middlewares, middlewares...)
+	}
+}
+
+func WithCommitPolicy(policy CommitPolicy) ConsumerOption {
+	return func(c *Consumer) {
+		c.commitPolicy = policy
+	}
+}
+
+// WithBatching limits the batches given to a BatchHandler: a batch is
+// passed on once it holds maxSize messages or maxLatency passed since its
+// first message arrived.
+func WithBatching(maxSize int, maxLatency time.Duration) ConsumerOption {
+	return func(c *Consumer) {
+		c.batchSize = maxSize
+		c.batchLatency = maxLatency
+	}
+}
+
+// WithPartitionConcurrency handles every partition in its own goroutine.
+// Messages of one partition are still handled one after another, so the
+// order of events sharing a producer is kept.
+func WithPartitionConcurrency() ConsumerOption {
+	return func(c *Consumer) {
+		c.concurrent = true
+	}
+}
+
+// WithStartFromEarliest rewinds every partition to its first retained
+// message once, when this Consumer first reads it. Later reconnects and
+// rebalances of the same Consumer resume from the committed offset, but
+// the rewind is only remembered in memory: a new Consumer, for example
+// after a process restart, rewinds again. Use ResetOffsets to move the
+// committed position once.
+func WithStartFromEarliest() ConsumerOption {
+	return func(c *Consumer) {
+		c.rewind = true
+	}
+}
+
+// WithStartFrom is WithStartFromEarliest that also skips every message
+// written before ts.
+func WithStartFrom(ts time.Time) ConsumerOption {
+	return func(c *Consumer) {
+		c.rewind = true
+		c.readFrom = ts
+	}
+}
+
+// WithName sets the name used in logs; defaults to consumer@topics.
+func WithName(name string) ConsumerOption {
+	return func(c *Consumer) {
+		c.name = name
+	}
+}
+
+// Consumer reads one or more topics as a named consumer and passes every
+// message to a Handler, committing it once the handler succeeded.
+type Consumer struct {
+	name         string
+	handler      Handler
+	middlewares  []Middleware
+	commitPolicy CommitPolicy
+	batchSize    int
+	batchLatency time.Duration
+	concurrent   bool
+	rewind       bool
+	readFrom     time.Time
+
+	topicReader *topicreader.Reader
+	loop        *readLoop
+}
+
+func NewConsumer(
+	topicClient topic.Client,
+	consumer string,
+	topics []string,
+	handler Handler,
+	opts ...ConsumerOption,
+) (*Consumer, error) {
+	var c, err = newConsumer(topicClient, consumer, topics, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.handler = Chain(handler, c.middlewares...)
+	c.loop = newReadLoop(c.name, c.topicReader, c.fetchMessage, c.handleMessage)
+	if c.concurrent {
+		c.loop.partitions = newPartitionDispatcher(c.loop)
+	}
+
+	return c, nil
+}
+
+// NewBatchConsumer is NewConsumer for handlers that process whole batches.
+// Middlewares do not apply to batch handlers.
+func NewBatchConsumer(
+	topicClient topic.Client,
+	consumer string,
+	topics []string,
+	handler BatchHandler,
+	opts ...ConsumerOption,
+) (*Consumer, error) {
+	var c, err = newConsumer(topicClient, consumer, topics, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.loop = newReadLoop(c.name, c.topicReader, c.fetchBatch, handler.HandleBatch)
+	if c.concurrent {
+		c.loop.partitions = newPartitionDispatcher(c.loop)
+	}
+
+	return c, nil
+}
+
+func newConsumer(
+	topicClient topic.Client,
+	consumer string,
+	topics []string,
+	opts []ConsumerOption,
+) (*Consumer, error) {
+	var c = &Consumer{
+		name:         consumer + "@" + strings.Join(topics, ","),
+		commitPolicy: CommitEveryMessage(),
+		batchSize:    defaultBatchSize,
+		batchLatency: defaultBatchLatency,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	var selectors = make(topicoptions.ReadSelectors, 0, len(topics))
+	for _, path := range topics {
+		selectors = append(selectors, topicoptions.ReadSelector{Path: path, ReadFrom: c.readFrom})
+	}
+
+	var readerOptions = append(
+		c.commitPolicy.readerOptions(),
+		// Autoscaling splits partitions; the server then hands out the
+		// children only after the parent was read to the end.
+		topicoptions.WithReaderSupportSplitMergePartitions(true),
+	)
+	if c.rewind {
+		readerOptions = append(
+			readerOptions,
+			topicoptions.WithReaderGetPartitionStartOffset(rewindOnce(topicClient)),
+		)
+	}
+
+	var reader, err = topicClient.StartReader(consumer, selectors, readerOptions...)
+	if err != nil {
+		return nil, err
+	}
+	c.topicReader = reader
+
+	return c, nil
+}
+
+func (c *Consumer) fetchMessage(ctx context.Context) (*delivery, error) {
+	raw, err := c.topicReader.ReadMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var next = &delivery{commits: []commitTarget{raw}}
+	next.addMessage(c.name, raw)
+	return next, nil
+}
+
+func (c *Consumer) handleMessage(ctx context.Context, messages []*Message) error {
+	return c.handler.Handle(ctx, messages[0])
+}
+
+// fetchBatch blocks for the first SDK batch and then keeps collecting until
+// batchSize messages are gathered or batchLatency runs out.
+func (c *Consumer) fetchBatch(ctx context.Context) (*delivery, error) {
+	var next = &delivery{}
+
+	batch, err := c.topicReader.ReadMessagesBatch(ctx, topicreader.WithBatchMaxCount(c.batchSize))
+	if err != nil {
+		return nil, err
+	}
+	next.addBatch(c.name, batch)
+
+	var collectCtx, cancel = context.WithTimeout(ctx, c.batchLatency)
+	defer cancel()
+
+	for len(next.messages) < c.batchSize {
+		batch, err := c.topicReader.ReadMessagesBatch(
+			collectCtx,
+			topicreader.WithBatchMaxCount(c.batchSize-len(next.messages)),
+		)
+		if err != nil {
+			// The latency ran out or ctx was cancelled: hand over what we
+			// have, the caller notices cancellation on its next read.
+			break
+		}
+		next.addBatch(c.name, batch)
+	}
+
+	return next, nil
+}
+
+// Run starts consuming in the background until ctx is cancelled or
+// Shutdown is called.
+func (c *Consumer) Run(ctx context.Context) {
+	c.loop.start(ctx)
+}
+
+// Shutdown lets the consumer finish and commit the messages already in
+// flight, then closes the reader. The reader is closed even when ctx
+// expires before the drain is over, within closeTimeout.
+func (c *Consumer) Shutdown(ctx context.Context) error {
+	var err = c.loop.shutdown(ctx)
+
+	var closeCtx, cancel = context.WithTimeout(context.WithoutCancel(ctx), closeTimeout)
+	defer cancel()
+
+	return errors.Join(err, c.topicReader.Close(closeCtx))
+}
+
+// Err reports why the consumer stopped: ErrShutdown after Shutdown, the
+// context cause after cancellation, nil while it is running.
+func (c *Consumer) Err() error {
+	return c.loop.exitReason()
+}
@@ -0,0 +1,183 @@
+package topic
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"ydb-sample/internal/query"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicoptions"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicwriter"
+)
+
+// The benchmarks compare per-message and batched consumption on a scratch
+// topic, so they need a YDB such as the container of
+// deployment/docker-compose.yml. They are skipped unless YDB_INTEGRATION
+// is set; YDB_DSN and the other YDB_* variables select the database.
+
+const (
+	benchTopic        = "bench_topic"
+	benchConsumer     = "bench"
+	benchBatchSize    = 500
+	benchBatchLatency = 50 * time.Millisecond
+	// benchTimeout fails a run that stops receiving instead of hanging.
+	benchTimeout = 2 * time.Minute
+)
+
+func BenchmarkConsumerPerMessage(b *testing.B) {
+	benchmarkConsumer(b, func(helper *query.QueryHelper, received func(int)) (*Consumer, error) {
+		return NewConsumer(
+			helper.Topic(),
+			benchConsumer,
+			[]string{benchTopic},
+			HandlerFunc(func(ctx context.Context, message *Message) error {
+				received(1)
+				return nil
+			}),
+		)
+	})
+}
+
+func BenchmarkConsumerBatched(b *testing.B) {
+	benchmarkConsumer(b, func(helper *query.QueryHelper, received func(int)) (*Consumer, error) {
+		return NewBatchConsumer(
+			helper.Topic(),
+			benchConsumer,
+			[]string{benchTopic},
+			BatchHandlerFunc(func(ctx context.Context, messages []*Message) error {
+				received(len(messages))
+				return nil
+			}),
+			WithBatching(benchBatchSize, benchBatchLatency),
+		)
+	})
+}
+
+// benchmarkConsumer fills a fresh topic with b.N messages and times how
+// long the consumer takes to handle all of them.
+func benchmarkConsumer(
+	b *testing.B,
+	newConsumer func(helper *query.QueryHelper, received func(int)) (*Consumer, error),
+) {
+	if os.Getenv("YDB_INTEGRATION") == "" {
+		b.Skip("YDB_INTEGRATION is not set")
+	}
+	var ctx = context.Background()
+
+	config, err := query.LoadConfig("")
+	if err != nil {
+		b.Fatal(err)
+	}
+	helper, err := query.NewQueryHelper(ctx, config)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		_ = helper.Execute(ctx, "DROP TOPIC IF EXISTS "+benchTopic+";")
+		_ = helper.Close(ctx)
+	})
+
+	err = resetBenchTopic(ctx, helper)
+	if err != nil {
+		b.Fatal(err)
+	}
+	err = fillBenchTopic(ctx, helper, b.N)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// Redelivered messages may push the count past b.N.
+	var count atomic.Int64
+	var done = make(chan struct{})
+	var doneOnce sync.Once
+	consumer, err := newConsumer(helper, func(n int) {
+		if count.Add(int64(n)) >= int64(b.N) {
+			doneOnce.Do(func() { close(done) })
+		}
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var stopped = false
+	b.Cleanup(func() {
+		if !stopped {
+			_ = consumer.Shutdown(ctx)
+		}
+	})
+
+	b.ResetTimer()
+	consumer.Run(ctx)
+	waitReceived(b, consumer, done, &count)
+	b.StopTimer()
+
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "msg/s")
+
+	stopped = true
+	err = consumer.Shutdown(ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+}
+
+// waitReceived blocks until done is closed and fails the benchmark if the
+// consumer stops or benchTimeout passes first.
+func waitReceived(b *testing.B, consumer *Consumer, done <-chan struct{}, count *atomic.Int64) {
+	var deadline = time.NewTimer(benchTimeout)
+	defer deadline.Stop()
+	var ticker = time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			var err = consumer.Err()
+			if err != nil {
+				b.Fatalf("consumer stopped after %d of %d messages: %v", count.Load(), b.N, err)
+			}
+		case <-deadline.C:
+			b.Fatalf("received %d of %d messages within %v", count.Load(), b.N, benchTimeout)
+		}
+	}
+}
+
+func resetBenchTopic(ctx context.Context, helper *query.QueryHelper) error {
+	return helper.Execute(ctx, fmt.Sprintf(`
+		DROP TOPIC IF EXISTS %[1]s;
+		CREATE TOPIC %[1]s(CONSUMER %[2]s);
+	`, benchTopic, benchConsumer))
+}
+
+func fillBenchTopic(ctx context.Context, helper *query.QueryHelper, count int) error {
+	writer, err := helper.Topic().StartWriter(
+		benchTopic,
+		topicoptions.WithWriterProducerID("producer-bench"),
+	)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < count; i++ {
+		err = writer.Write(ctx, topicwriter.Message{
+			Data: bytes.NewReader([]byte("message " + strconv.Itoa(i))),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writer.Flush(ctx)
+	if err != nil {
+		return err
+	}
+
+	return writer.Close(ctx)
+}
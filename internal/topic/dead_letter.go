@@ -0,0 +1,301 @@
+package topic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+	"ydb-sample/internal/statusevent"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicoptions"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicreader"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicwriter"
+)
+
+const (
+	DeadLetterTopic = "task_status_dlq"
+
+	// Consumers of the dead-letter topic created by SchemaRepository.
+	deadLetterInspectConsumer = "inspect"
+	deadLetterRedriveConsumer = "redrive"
+)
+
+// Metadata keys a dead-lettered message carries next to its original
+// metadata. All of them share the dlq- prefix, which re-drive strips.
+const (
+	deadLetterPrefix        = "dlq-"
+	MetadataDeadLetterError = deadLetterPrefix + "error"
+	MetadataSourceTopic     = deadLetterPrefix + "source-topic"
+	MetadataSourcePartition = deadLetterPrefix + "source-partition"
+	MetadataSourceOffset    = deadLetterPrefix + "source-offset"
+	MetadataFailedAt        = deadLetterPrefix + "failed-at"
+)
+
+// DeadLetterWriter is a DeadLetterSink writing to a dead-letter topic.
+type DeadLetterWriter struct {
+	topicWriter *topicwriter.Writer
+}
+
+func NewDeadLetterWriter(topicClient topic.Client, path string) (*DeadLetterWriter, error) {
+	var topicWriter, err = topicClient.StartWriter(
+		path,
+		topicoptions.WithWriterProducerID("producer-"+path),
+		topicoptions.WithWriterWaitServerAck(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeadLetterWriter{
+		topicWriter: topicWriter,
+	}, nil
+}
+
+func (w *DeadLetterWriter) DeadLetter(ctx context.Context, message *Message, cause error) error {
+	var metadata = maps.Clone(message.Metadata)
+	if metadata == nil {
+		metadata = make(map[string][]byte)
+	}
+	metadata[MetadataDeadLetterError] = []byte(cause.Error())
+	metadata[MetadataSourceTopic] = []byte(message.Topic)
+	metadata[MetadataSourcePartition] = []byte(strconv.FormatInt(message.PartitionId, 10))
+	metadata[MetadataSourceOffset] = []byte(strconv.FormatInt(message.Offset, 10))
+	metadata[MetadataFailedAt] = []byte(time.Now().UTC().Format(time.RFC3339Nano))
+
+	return w.topicWriter.Write(ctx, topicwriter.Message{
+		CreatedAt: message.CreatedAt,
+		Data:      bytes.NewReader(message.Data),
+		Metadata:  metadata,
+	})
+}
+
+func (w *DeadLetterWriter) Close(ctx context.Context) error {
+	return w.topicWriter.Close(ctx)
+}
+
+// DeadLetterRecord is a message read back from a dead-letter topic.
+type DeadLetterRecord struct {
+	Offset          int64
+	SourceTopic     string
+	SourcePartition string
+	SourceOffset    string
+	FailedAt        string
+	Error           string
+	Metadata        map[string][]byte
+	Data            []byte
+}
+
+func newDeadLetterRecord(message *Message) DeadLetterRecord {
+	var original = maps.Clone(message.Metadata)
+	maps.DeleteFunc(original, func(key string, _ []byte) bool {
+		return strings.HasPrefix(key, deadLetterPrefix)
+	})
+
+	return DeadLetterRecord{
+		Offset:          message.Offset,
+		SourceTopic:     string(message.Metadata[MetadataSourceTopic]),
+		SourcePartition: string(message.Metadata[MetadataSourcePartition]),
+		SourceOffset:    string(message.Metadata[MetadataSourceOffset]),
+		FailedAt:        string(message.Metadata[MetadataFailedAt]),
+		Error:           string(message.Metadata[MetadataDeadLetterError]),
+		Metadata:        original,
+		Data:            message.Data,
+	}
+}
+
+// InspectDeadLetters returns up to limit messages of the dead-letter topic
+// without committing them, so repeated calls see the same messages. Every
+// partition is read from the redrive consumer's committed offset, so
+// messages that were already re-driven are not listed.
+func InspectDeadLetters(
+	ctx context.Context,
+	topicClient topic.Client,
+	path string,
+	limit int,
+) ([]DeadLetterRecord, error) {
+	redriven, err := committedOffsets(ctx, topicClient, path, deadLetterRedriveConsumer)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := topicClient.StartReader(
+		deadLetterInspectConsumer,
+		topicoptions.ReadTopic(path),
+		topicoptions.WithReaderCommitMode(topicoptions.CommitModeNone),
+		topicoptions.WithReaderGetPartitionStartOffset(func(
+			ctx context.Context,
+			req topicoptions.GetPartitionStartOffsetRequest,
+		) (topicoptions.GetPartitionStartOffsetResponse, error) {
+			var response topicoptions.GetPartitionStartOffsetResponse
+			if offset, ok := redriven[req.PartitionID]; ok {
+				response.StartFrom(offset)
+			}
+			return response, nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close(ctx) }()
+
+	var result = make([]DeadLetterRecord, 0)
+	for len(result) < limit {
+		raw, err := readWithin(ctx, reader, drainTimeout)
+		if errors.Is(err, context.DeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+
+		message, err := newMessage(raw)
+		if err != nil {
+			return result, err
+		}
+
+		result = append(result, newDeadLetterRecord(message))
+	}
+
+	return result, nil
+}
+
+// RedriveDeadLetters writes up to limit dead-lettered messages back to
+// their source topics with the original metadata and commits them in the
+// dead-letter topic. It returns how many messages were re-driven.
+//
+// Events are written by the producer the outbox relay uses for their
+// issue, so pass the relay's producer count; events of one issue then
+// stay in one partition and in order.
+func RedriveDeadLetters(
+	ctx context.Context,
+	topicClient topic.Client,
+	path string,
+	producers int,
+	limit int,
+) (int, error) {
+	if producers < 1 {
+		return 0, errors.New("re-drive needs at least one producer")
+	}
+
+	var reader, err = topicClient.StartReader(
+		deadLetterRedriveConsumer,
+		topicoptions.ReadTopic(path),
+		topicoptions.WithReaderCommitMode(topicoptions.CommitModeSync),
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = reader.Close(ctx) }()
+
+	var writers = make(map[string]*topicwriter.Writer)
+	defer func() {
+		for _, writer := range writers {
+			_ = writer.Close(ctx)
+		}
+	}()
+
+	var redriven = 0
+	for redriven < limit {
+		raw, err := readWithin(ctx, reader, drainTimeout)
+		if errors.Is(err, context.DeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			return redriven, err
+		}
+
+		message, err := newMessage(raw)
+		if err != nil {
+			return redriven, err
+		}
+
+		var deadLetter = newDeadLetterRecord(message)
+		if deadLetter.SourceTopic == "" {
+			return redriven, errors.New("dead letter without source topic at offset " +
+				strconv.FormatInt(message.Offset, 10))
+		}
+
+		var producerId = redriveProducerId(deadLetter, producers)
+		var key = deadLetter.SourceTopic + "/" + producerId
+		var writer, ok = writers[key]
+		if !ok {
+			writer, err = topicClient.StartWriter(
+				deadLetter.SourceTopic,
+				topicoptions.WithWriterProducerID(producerId),
+				topicoptions.WithWriterWaitServerAck(true),
+			)
+			if err != nil {
+				return redriven, err
+			}
+			writers[key] = writer
+		}
+
+		err = writer.Write(ctx, topicwriter.Message{
+			CreatedAt: message.CreatedAt,
+			Data:      bytes.NewReader(deadLetter.Data),
+			Metadata:  deadLetter.Metadata,
+		})
+		if err != nil {
+			return redriven, err
+		}
+
+		err = reader.Commit(raw.Context(), raw)
+		if err != nil {
+			return redriven, err
+		}
+		redriven++
+	}
+
+	return redriven, nil
+}
+
+// committedOffsets returns, per partition of path, the offset consumer
+// reads next.
+func committedOffsets(
+	ctx context.Context,
+	topicClient topic.Client,
+	path string,
+	consumer string,
+) (map[int64]int64, error) {
+	description, err := topicClient.DescribeTopicConsumer(
+		ctx,
+		path,
+		consumer,
+		topicoptions.IncludeConsumerStats(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var result = make(map[int64]int64, len(description.Partitions))
+	for _, partition := range description.Partitions {
+		result[partition.PartitionID] = partition.PartitionConsumerStats.CommittedOffset
+	}
+	return result, nil
+}
+
+// redriveProducerId returns the relay's producer for the event's issue. A
+// payload that does not decode has no issue to keep in order, and will
+// fail again anyway, so it goes through a producer of its own.
+func redriveProducerId(deadLetter DeadLetterRecord, producers int) string {
+	event, err := statusevent.Decode(deadLetter.Data, deadLetter.Metadata)
+	if err != nil {
+		return "producer-redrive"
+	}
+	return taskStatusProducerId(taskStatusProducer(event.IssueId, producers))
+}
+
+func readWithin(
+	ctx context.Context,
+	reader *topicreader.Reader,
+	timeout time.Duration,
+) (*topicreader.Message, error) {
+	var readCtx, cancel = context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return reader.ReadMessage(readCtx)
+}
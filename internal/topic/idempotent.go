@@ -0,0 +1,64 @@
+package topic
+
+import (
+	"context"
+	"log"
+	"ydb-sample/internal/dedup"
+	"ydb-sample/internal/query"
+
+	ydbQuery "github.com/ydb-platform/ydb-go-sdk/v3/query"
+)
+
+// TxHandler applies a message inside a YDB transaction opened by Idempotent.
+type TxHandler interface {
+	HandleTx(ctx context.Context, tx ydbQuery.TxActor, message *Message) error
+}
+
+type TxHandlerFunc func(ctx context.Context, tx ydbQuery.TxActor, message *Message) error
+
+func (f TxHandlerFunc) HandleTx(ctx context.Context, tx ydbQuery.TxActor, message *Message) error {
+	return f(ctx, tx, message)
+}
+
+// EventIdFunc extracts the id a message is deduplicated by.
+type EventIdFunc func(message *Message) (string, error)
+
+// Idempotent turns a TxHandler into a Handler that applies every event at
+// most once per consumer. The handler's writes and the processed_events
+// record commit in one transaction, so a message redelivered after a crash
+// before Commit finds its id recorded and is skipped.
+func Idempotent(
+	helper *query.QueryHelper,
+	processed *dedup.ProcessedEventRepository,
+	consumer string,
+	eventId EventIdFunc,
+	handler TxHandler,
+) Handler {
+	return HandlerFunc(func(ctx context.Context, message *Message) error {
+		id, err := eventId(message)
+		if err != nil {
+			return err
+		}
+
+		return helper.ExecuteInTx(
+			ctx,
+			func(ctx context.Context, tx ydbQuery.TxActor) error {
+				done, err := processed.IsProcessed(ctx, tx, consumer, id)
+				if err != nil {
+					return err
+				}
+				if done {
+					log.Printf("%s: event %s already processed, skipping\n", consumer, id)
+					return nil
+				}
+
+				err = handler.HandleTx(ctx, tx, message)
+				if err != nil {
+					return err
+				}
+
+				return processed.MarkProcessed(ctx, tx, consumer, id)
+			},
+		)
+	})
+}
@@ -0,0 +1,59 @@
+package topic
+
+import (
+	"context"
+	"log"
+	"ydb-sample/internal/changefeed"
+	"ydb-sample/internal/issue"
+	"ydb-sample/internal/query"
+
+	ydbQuery "github.com/ydb-platform/ydb-go-sdk/v3/query"
+)
+
+// NewIssueHistoryProjector consumes the issues/updates changefeed as the
+// history consumer and appends every changed column to issue_history. It
+// reads in transactions, so offsets and history rows commit together.
+func NewIssueHistoryProjector(
+	helper *query.QueryHelper,
+	issueRepo *issue.IssueRepository,
+	opts ...ConsumerOption,
+) (*Consumer, error) {
+	return NewTransactionalConsumer(
+		helper,
+		"history",
+		[]string{"issues/updates"},
+		TxBatchHandlerFunc(func(ctx context.Context, tx ydbQuery.TxActor, messages []*Message) error {
+			return issueRepo.AppendHistoryInTx(ctx, tx, issueHistoryEntries(messages))
+		}),
+		append([]ConsumerOption{WithName("issue history projector")}, opts...)...,
+	)
+}
+
+func issueHistoryEntries(messages []*Message) []issue.HistoryEntry {
+	var entries = make([]issue.HistoryEntry, 0, len(messages))
+
+	for _, message := range messages {
+		change, err := changefeed.DecodeIssueChange(message.Data)
+		if err != nil {
+			log.Printf("Skipping undecodable record at offset %d: %v\n", message.Offset, err)
+			continue
+		}
+		if change.Kind == changefeed.KindResolved {
+			continue
+		}
+
+		for _, column := range change.ChangedColumns() {
+			entries = append(entries, issue.HistoryEntry{
+				IssueId:  change.Key,
+				Step:     change.VirtualTimestamp.Step,
+				TxId:     change.VirtualTimestamp.TxId,
+				Column:   column.Column,
+				Kind:     change.Kind.String(),
+				OldValue: column.OldValue,
+				NewValue: column.NewValue,
+			})
+		}
+	}
+
+	return entries
+}
@@ -0,0 +1,108 @@
+package topic
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+type Middleware func(Handler) Handler
+
+// Chain wraps handler so that middlewares[0] runs first.
+func Chain(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// Logging logs every failed message and, when verbose, every handled one.
+func Logging(name string, verbose bool) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, message *Message) error {
+			var err = next.Handle(ctx, message)
+			if err != nil {
+				log.Printf(
+					"%s: %s/%d offset %d failed: %v\n",
+					name, message.Topic, message.PartitionId, message.Offset, err,
+				)
+			} else if verbose {
+				log.Printf(
+					"%s: %s/%d offset %d handled\n",
+					name, message.Topic, message.PartitionId, message.Offset,
+				)
+			}
+			return err
+		})
+	}
+}
+
+// ConsumerMetrics counts handler outcomes; safe for concurrent use.
+type ConsumerMetrics struct {
+	Handled      atomic.Int64
+	Failed       atomic.Int64
+	HandlingTime atomic.Int64 // nanoseconds spent in the handler
+}
+
+func Metrics(metrics *ConsumerMetrics) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, message *Message) error {
+			var start = time.Now()
+			var err = next.Handle(ctx, message)
+			metrics.HandlingTime.Add(int64(time.Since(start)))
+			if err != nil {
+				metrics.Failed.Add(1)
+			} else {
+				metrics.Handled.Add(1)
+			}
+			return err
+		})
+	}
+}
+
+// Retry calls the handler up to attempts times with exponential backoff
+// and returns the last error.
+func Retry(attempts int) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, message *Message) error {
+			var retry backoff
+			var err error
+			for attempt := 1; ; attempt++ {
+				err = next.Handle(ctx, message)
+				if err == nil || attempt >= attempts {
+					return err
+				}
+				if !sleep(ctx, retry.next()) {
+					return err
+				}
+			}
+		})
+	}
+}
+
+// DeadLetterSink stores messages that could not be handled.
+type DeadLetterSink interface {
+	DeadLetter(ctx context.Context, message *Message, cause error) error
+}
+
+// DeadLetter hands failed messages to sink and reports them as handled, so
+// one poison message does not block the partition. If the sink fails too
+// the original error is returned and the message is retried.
+func DeadLetter(sink DeadLetterSink) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, message *Message) error {
+			var err = next.Handle(ctx, message)
+			if err == nil {
+				return nil
+			}
+
+			var sinkErr = sink.DeadLetter(ctx, message, err)
+			if sinkErr != nil {
+				log.Printf("Dead-lettering offset %d failed: %v\n", message.Offset, sinkErr)
+				return err
+			}
+			return nil
+		})
+	}
+}
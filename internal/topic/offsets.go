@@ -0,0 +1,173 @@
+package topic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"ydb-sample/internal/query"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicoptions"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topictypes"
+)
+
+const (
+	// offsetSearchTimeout is how long ResetOffsets waits for the next
+	// message before it treats the remaining partitions as drained.
+	offsetSearchTimeout = 5 * time.Second
+	// offsetSearchLimit bounds the whole search: under steady traffic the
+	// idle timeout never fires while other partitions keep writing.
+	offsetSearchLimit = 30 * time.Second
+)
+
+// PartitionOffset is the committed offset ResetOffsets set for a partition.
+type PartitionOffset struct {
+	PartitionId int64
+	Offset      int64
+}
+
+// rewindOnce starts a partition at its first retained message the first
+// time this reader gets it; reconnects and rebalances later resume from
+// the committed offset. The set of rewound partitions lives in memory, so
+// a restarted process rewinds again.
+func rewindOnce(topicClient topic.Client) topicoptions.GetPartitionStartOffsetFunc {
+	var mu sync.Mutex
+	var started = make(map[string]bool)
+
+	return func(
+		ctx context.Context,
+		req topicoptions.GetPartitionStartOffsetRequest,
+	) (topicoptions.GetPartitionStartOffsetResponse, error) {
+		var response topicoptions.GetPartitionStartOffsetResponse
+		var key = partitionKey(req.Topic, req.PartitionID)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if started[key] {
+			return response, nil
+		}
+
+		partitions, err := describePartitions(ctx, topicClient, req.Topic)
+		if err != nil {
+			return response, err
+		}
+		partition, ok := partitions[req.PartitionID]
+		if !ok {
+			return response, fmt.Errorf("partition %d of %s not found", req.PartitionID, req.Topic)
+		}
+
+		response.StartFrom(partition.PartitionStats.PartitionsOffset.Start)
+		started[key] = true
+		return response, nil
+	}
+}
+
+func describePartitions(
+	ctx context.Context,
+	topicClient topic.Client,
+	path string,
+) (map[int64]topictypes.PartitionInfo, error) {
+	description, err := topicClient.Describe(ctx, path, topicoptions.IncludePartitionStats())
+	if err != nil {
+		return nil, err
+	}
+
+	var result = make(map[int64]topictypes.PartitionInfo, len(description.Partitions))
+	for _, partition := range description.Partitions {
+		result[partition.PartitionID] = partition
+	}
+	return result, nil
+}
+
+// ResetOffsets moves the committed offsets of consumer on path so that it
+// next reads the first message written at or after ts, or the earliest
+// retained message when ts is zero. Partitions without such a message are
+// set to their end. Stop the consumer's readers first: a running reader
+// keeps committing from its own position.
+func ResetOffsets(
+	ctx context.Context,
+	helper *query.QueryHelper,
+	path string,
+	consumer string,
+	ts time.Time,
+) ([]PartitionOffset, error) {
+	partitions, err := describePartitions(ctx, helper.Topic(), path)
+	if err != nil {
+		return nil, err
+	}
+
+	var offsets = make(map[int64]int64, len(partitions))
+	if ts.IsZero() {
+		for id, partition := range partitions {
+			offsets[id] = partition.PartitionStats.PartitionsOffset.Start
+		}
+	} else {
+		offsets, err = firstOffsetsAfter(ctx, helper.Topic(), path, ts, len(partitions))
+		if err != nil {
+			return nil, err
+		}
+		for id, partition := range partitions {
+			if _, ok := offsets[id]; !ok {
+				offsets[id] = partition.PartitionStats.PartitionsOffset.End
+			}
+		}
+	}
+
+	var result = make([]PartitionOffset, 0, len(offsets))
+	for id, offset := range offsets {
+		err = helper.CommitTopicOffset(ctx, path, id, consumer, offset)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, PartitionOffset{PartitionId: id, Offset: offset})
+	}
+
+	return result, nil
+}
+
+// firstOffsetsAfter reads path without a consumer and returns, per
+// partition, the offset of the first message written at or after ts.
+// Partitions that yield nothing within offsetSearchLimit are left out.
+func firstOffsetsAfter(
+	ctx context.Context,
+	topicClient topic.Client,
+	path string,
+	ts time.Time,
+	partitions int,
+) (map[int64]int64, error) {
+	var searchCtx, cancel = context.WithTimeout(ctx, offsetSearchLimit)
+	defer cancel()
+
+	var reader, err = topicClient.StartReader(
+		"",
+		topicoptions.ReadSelectors{{Path: path, ReadFrom: ts}},
+		topicoptions.WithReaderWithoutConsumer(false),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close(ctx) }()
+
+	var result = make(map[int64]int64, partitions)
+	for len(result) < partitions {
+		raw, err := readWithin(searchCtx, reader, offsetSearchTimeout)
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+
+		if _, ok := result[raw.PartitionID()]; !ok {
+			result[raw.PartitionID()] = raw.Offset
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,228 @@
+package topic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"hash/fnv"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+	"ydb-sample/internal/outbox"
+	"ydb-sample/internal/statusevent"
+	"ydb-sample/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicoptions"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicwriter"
+)
+
+const (
+	outboxPollInterval = 200 * time.Millisecond
+	outboxBatchSize    = 100
+)
+
+// OutboxRelay publishes pending outbox messages to the task_status topic and
+// deletes them once the server acknowledged them. A crash between the
+// two steps republishes the batch, so delivery is at-least-once.
+//
+// Messages are spread over a fixed set of producers by issue id. A topic
+// keeps all messages of one producer in one partition and in write order,
+// so every event of an issue is ordered while different issues are spread
+// over the partitions.
+type OutboxRelay struct {
+	outboxRepo  *outbox.OutboxRepository
+	topicClient topic.Client
+	stopChannel chan struct{}
+	stopOnce    sync.Once
+	quitChannel chan bool
+	running     atomic.Bool
+
+	// mu guards topicWriters, which stay closed once closed is set.
+	mu           sync.Mutex
+	topicWriters []*topicwriter.Writer
+	closed       bool
+}
+
+// NewOutboxRelay creates a relay writing through producers producers; use
+// at least as many as the topic may have active partitions.
+func NewOutboxRelay(
+	outboxRepo *outbox.OutboxRepository,
+	topicClient topic.Client,
+	producers int,
+) (*OutboxRelay, error) {
+	if producers < 1 {
+		return nil, errors.New("outbox relay needs at least one producer")
+	}
+
+	return &OutboxRelay{
+		outboxRepo:   outboxRepo,
+		topicClient:  topicClient,
+		topicWriters: make([]*topicwriter.Writer, producers),
+		stopChannel:  make(chan struct{}),
+		quitChannel:  make(chan bool),
+	}, nil
+}
+
+func (r *OutboxRelay) Run(ctx context.Context) {
+	var goroutine = func() {
+		var ticker = time.NewTicker(outboxPollInterval)
+		defer ticker.Stop()
+		defer close(r.quitChannel)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.stopChannel:
+				return
+			case <-ticker.C:
+				var _, err = r.relay(ctx)
+				if err != nil {
+					log.Printf("Outbox relay error: %v\n", err)
+				}
+			}
+		}
+	}
+	r.running.Store(true)
+	go goroutine()
+}
+
+// relay publishes one batch and returns how many messages it sent.
+func (r *OutboxRelay) relay(ctx context.Context) (int, error) {
+	var pending, err = r.outboxRepo.FindPending(ctx, outboxBatchSize)
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+
+	// Group by producer keeping the outbox order inside every group.
+	var groups = make(map[int][]topicwriter.Message)
+	for _, m := range pending {
+		var producer = r.producerFor(m.IssueId)
+		groups[producer] = append(groups[producer], topicwriter.Message{
+			CreatedAt: m.CreatedAt,
+			Data:      bytes.NewReader(m.Payload),
+			Metadata: map[string][]byte{
+				statusevent.MetadataContentType:   []byte(m.ContentType),
+				statusevent.MetadataSchemaVersion: []byte(strconv.FormatUint(uint64(m.SchemaVersion), 10)),
+			},
+		})
+	}
+
+	for producer, messages := range groups {
+		writer, err := r.writer(producer)
+		if err != nil {
+			return 0, err
+		}
+
+		err = writer.Write(ctx, messages...)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var ids = utils.Mapped(&pending, func(i int, m outbox.Message) uuid.UUID {
+		return m.Id
+	})
+
+	err = r.outboxRepo.DeleteSent(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(pending), nil
+}
+
+func (r *OutboxRelay) producerFor(issueId uuid.UUID) int {
+	return taskStatusProducer(issueId, len(r.topicWriters))
+}
+
+// taskStatusProducer picks which of producers writes the events of an
+// issue. Everything writing to task_status must agree on it, or events of
+// one issue end up in different partitions.
+func taskStatusProducer(issueId uuid.UUID, producers int) int {
+	var hash = fnv.New32a()
+	_, _ = hash.Write(issueId[:])
+	return int(hash.Sum32() % uint32(producers))
+}
+
+func taskStatusProducerId(producer int) string {
+	return "producer-task-status-" + strconv.Itoa(producer)
+}
+
+func (r *OutboxRelay) writer(producer int) (*topicwriter.Writer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, errors.New("outbox relay is shut down")
+	}
+	if r.topicWriters[producer] != nil {
+		return r.topicWriters[producer], nil
+	}
+
+	var topicWriter, err = r.topicClient.StartWriter(
+		"task_status",
+		topicoptions.WithWriterProducerID(taskStatusProducerId(producer)),
+		topicoptions.WithWriterWaitServerAck(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	r.topicWriters[producer] = topicWriter
+	return topicWriter, nil
+}
+
+// Shutdown stops polling, publishes whatever is still pending and closes
+// the writers. It also works for a relay that was never run and may be
+// called more than once. The writers are closed even when ctx expires
+// first, within closeTimeout.
+func (r *OutboxRelay) Shutdown(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stopChannel) })
+
+	var err = r.flush(ctx)
+
+	var closeCtx, cancel = context.WithTimeout(context.WithoutCancel(ctx), closeTimeout)
+	defer cancel()
+
+	return errors.Join(err, r.closeWriters(closeCtx))
+}
+
+// flush waits for the polling goroutine to stop and relays until the
+// outbox is empty.
+func (r *OutboxRelay) flush(ctx context.Context) error {
+	if r.running.Load() {
+		select {
+		case <-r.quitChannel:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for {
+		var sent, err = r.relay(ctx)
+		if err != nil || sent == 0 {
+			return err
+		}
+	}
+}
+
+func (r *OutboxRelay) closeWriters(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+
+	var errs []error
+	for i, writer := range r.topicWriters {
+		if writer != nil {
+			errs = append(errs, writer.Close(ctx))
+			r.topicWriters[i] = nil
+		}
+	}
+	return errors.Join(errs...)
+}
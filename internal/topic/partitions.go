@@ -0,0 +1,180 @@
+package topic
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// maxBufferedDeliveries caps the deliveries waiting in all partitions
+// together. Only when it is reached does reading stop; a single slow
+// partition piles up its own deliveries without holding back the others.
+const maxBufferedDeliveries = 1024
+
+// partitionDispatcher lets a readLoop handle partitions concurrently: every
+// partition gets its own goroutine that processes deliveries in order.
+type partitionDispatcher struct {
+	loop    *readLoop
+	queues  map[string]*partitionQueue
+	slots   chan struct{}
+	workers sync.WaitGroup
+}
+
+func newPartitionDispatcher(loop *readLoop) *partitionDispatcher {
+	return &partitionDispatcher{
+		loop:   loop,
+		queues: make(map[string]*partitionQueue),
+		slots:  make(chan struct{}, maxBufferedDeliveries),
+	}
+}
+
+// dispatch splits a delivery by partition and queues the parts. It never
+// waits for a partition, only for room in the shared buffer; parts that
+// find no room before ctx ends stay uncommitted and are delivered again.
+func (d *partitionDispatcher) dispatch(ctx context.Context, next *delivery) {
+	var parts = make(map[string]*delivery)
+	var part = func(key string) *delivery {
+		if parts[key] == nil {
+			parts[key] = &delivery{}
+		}
+		return parts[key]
+	}
+
+	for _, message := range next.messages {
+		var key = partitionKey(message.Topic, message.PartitionId)
+		part(key).messages = append(part(key).messages, message)
+	}
+	for _, commit := range next.commits {
+		var key = partitionKey(commit.Topic(), commit.PartitionID())
+		part(key).commits = append(part(key).commits, commit)
+	}
+
+	for key, p := range parts {
+		select {
+		case d.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		var queue, ok = d.queues[key]
+		if !ok {
+			queue = newPartitionQueue()
+			d.queues[key] = queue
+			d.workers.Add(1)
+			go d.work(ctx, key, queue)
+		}
+		queue.push(p)
+	}
+}
+
+func (d *partitionDispatcher) work(ctx context.Context, key string, queue *partitionQueue) {
+	defer d.workers.Done()
+
+	var retry backoff
+	var stopped = false
+
+	for {
+		next, ok := queue.pop()
+		if !ok {
+			return
+		}
+		d.handle(ctx, key, next, &retry, &stopped)
+		<-d.slots
+	}
+}
+
+func (d *partitionDispatcher) handle(
+	ctx context.Context,
+	key string,
+	next *delivery,
+	retry *backoff,
+	stopped *bool,
+) {
+	// Keep emptying the queue after a stop so its slots are released.
+	if *stopped || ctx.Err() != nil {
+		return
+	}
+
+	// The partition session ends when the partition moves to another
+	// reader or is split or merged by autoscaling. Its messages will be
+	// delivered to the new owner, so they are neither handled nor
+	// committed here.
+	if len(next.commits) > 0 && next.commits[0].Context().Err() != nil {
+		log.Printf("%s: partition %s stopped, dropping %d messages\n",
+			d.loop.name, key, len(next.messages))
+		return
+	}
+
+	var err = d.loop.process(ctx, next, retry)
+	if err != nil {
+		*stopped = true
+		return
+	}
+	retry.reset()
+}
+
+// wait closes every queue and waits until the queued deliveries are done.
+func (d *partitionDispatcher) wait() {
+	for _, queue := range d.queues {
+		queue.close()
+	}
+	d.workers.Wait()
+}
+
+// partitionQueue is an unbounded FIFO of one partition's deliveries.
+type partitionQueue struct {
+	mu      sync.Mutex
+	pending []*delivery
+	closed  bool
+	wake    chan struct{}
+}
+
+func newPartitionQueue() *partitionQueue {
+	return &partitionQueue{wake: make(chan struct{}, 1)}
+}
+
+func (q *partitionQueue) push(next *delivery) {
+	q.mu.Lock()
+	q.pending = append(q.pending, next)
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *partitionQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+// pop waits for the next delivery; false once the queue is closed and empty.
+func (q *partitionQueue) pop() (*delivery, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.pending) > 0 {
+			var next = q.pending[0]
+			q.pending[0] = nil
+			q.pending = q.pending[1:]
+			q.mu.Unlock()
+			return next, true
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return nil, false
+		}
+		q.mu.Unlock()
+		<-q.wake
+	}
+}
+
+func (q *partitionQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func partitionKey(topic string, partition int64) string {
+	return fmt.Sprintf("%s/%d", topic, partition)
+}
@@ -0,0 +1,246 @@
+package topic
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicreader"
+)
+
+// ErrShutdown is the exit reason of a worker stopped by Shutdown.
+var ErrShutdown = errors.New("worker shut down")
+
+// drainTimeout is how long a stopping worker keeps reading messages that
+// are already on their way. It counts from the stop request, so steady
+// traffic cannot keep a stopping worker alive.
+const drainTimeout = time.Second
+
+// closeTimeout bounds closing a reader or writer during shutdown, when the
+// caller's context may already be done.
+const closeTimeout = 5 * time.Second
+
+// delivery is what one read hands to the handler: a single message or a
+// batch, plus the ranges to commit once the handler succeeded.
+type delivery struct {
+	messages []*Message
+	commits  []commitTarget
+}
+
+// commitTarget is a topicreader.Message or topicreader.Batch.
+type commitTarget interface {
+	topicreader.CommitRangeGetter
+	Context() context.Context
+	Topic() string
+	PartitionID() int64
+}
+
+// addMessage converts raw and appends it. A payload that cannot be decompressed
+// will never reach any handler, so it is only committed.
+func (d *delivery) addMessage(name string, raw *topicreader.Message) {
+	message, err := newMessage(raw)
+	if err != nil {
+		log.Printf("%s: skipping unreadable message at offset %d: %v\n", name, raw.Offset, err)
+		return
+	}
+	d.messages = append(d.messages, message)
+}
+
+func (d *delivery) addBatch(name string, batch *topicreader.Batch) {
+	for _, raw := range batch.Messages {
+		d.addMessage(name, raw)
+	}
+	d.commits = append(d.commits, batch)
+}
+
+// readLoop is the read-handle-commit cycle shared by the topic workers.
+// It stops when its context is cancelled or after draining on stop(),
+// retries failed reads and handlers with exponential backoff and remembers
+// why it exited.
+type readLoop struct {
+	name        string
+	topicReader *topicreader.Reader
+	fetch       func(context.Context) (*delivery, error)
+	handle      func(context.Context, []*Message) error
+	partitions  *partitionDispatcher
+
+	stopChannel chan struct{}
+	doneChannel chan struct{}
+	cancel      context.CancelCauseFunc
+	err         error
+
+	// drainDeadline is set before stopChannel is closed.
+	drainDeadline time.Time
+}
+
+func newReadLoop(
+	name string,
+	topicReader *topicreader.Reader,
+	fetch func(context.Context) (*delivery, error),
+	handle func(context.Context, []*Message) error,
+) *readLoop {
+	return &readLoop{
+		name:        name,
+		topicReader: topicReader,
+		fetch:       fetch,
+		handle:      handle,
+		stopChannel: make(chan struct{}),
+		doneChannel: make(chan struct{}),
+	}
+}
+
+func (l *readLoop) start(ctx context.Context) {
+	ctx, l.cancel = context.WithCancelCause(ctx)
+	go func() {
+		defer close(l.doneChannel)
+		l.err = l.run(ctx)
+		if l.partitions != nil {
+			l.partitions.wait()
+		}
+		log.Printf("Stopping %s: %v\n", l.name, l.err)
+	}()
+}
+
+func (l *readLoop) run(ctx context.Context) error {
+	var retry backoff
+
+	for {
+		next, err := l.read(ctx)
+		if err != nil {
+			if l.stopping() && errors.Is(err, context.DeadlineExceeded) {
+				return ErrShutdown
+			}
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+
+			var delay = retry.next()
+			log.Printf("%s: read failed, retrying in %v: %v\n", l.name, delay, err)
+			if !sleep(ctx, delay) {
+				return context.Cause(ctx)
+			}
+			continue
+		}
+
+		retry.reset()
+		if l.partitions != nil {
+			l.partitions.dispatch(ctx, next)
+			continue
+		}
+
+		err = l.process(ctx, next, &retry)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// read waits for the next delivery; once stopping it only reads until the
+// drain deadline.
+func (l *readLoop) read(ctx context.Context) (*delivery, error) {
+	if !l.stopping() {
+		var readCtx, cancel = context.WithCancel(ctx)
+		defer cancel()
+
+		go func() {
+			select {
+			case <-l.stopChannel:
+				cancel()
+			case <-readCtx.Done():
+			}
+		}()
+
+		next, err := l.fetch(readCtx)
+		if err == nil || !l.stopping() {
+			return next, err
+		}
+	}
+
+	var readCtx, cancel = context.WithDeadline(ctx, l.drainDeadline)
+	defer cancel()
+
+	return l.fetch(readCtx)
+}
+
+// process runs the handler until it succeeds and commits the delivery. A
+// failing delivery is retried with backoff; once the loop is stopping it is
+// left uncommitted so it is delivered again after restart.
+func (l *readLoop) process(
+	ctx context.Context,
+	next *delivery,
+	retry *backoff,
+) error {
+	for len(next.messages) > 0 {
+		var err = l.handle(ctx, next.messages)
+		if err == nil {
+			break
+		}
+
+		if l.stopping() {
+			return ErrShutdown
+		}
+
+		var delay = retry.next()
+		log.Printf("%s: handler failed, retrying in %v: %v\n", l.name, delay, err)
+		if !sleep(ctx, delay) {
+			return context.Cause(ctx)
+		}
+	}
+
+	// Commit failures are only logged: the messages were handled, and at
+	// worst they are delivered once more after a reconnect.
+	for _, commit := range next.commits {
+		var err = l.topicReader.Commit(commit.Context(), commit)
+		if err != nil {
+			log.Printf("%s: commit failed: %v\n", l.name, err)
+		}
+	}
+
+	return nil
+}
+
+func (l *readLoop) stopping() bool {
+	select {
+	case <-l.stopChannel:
+		return true
+	default:
+		return false
+	}
+}
+
+// shutdown asks the loop to drain and waits until it exits. If ctx expires
+// first the loop is cancelled, so nothing is handled or committed after
+// shutdown returned.
+func (l *readLoop) shutdown(ctx context.Context) error {
+	select {
+	case <-l.stopChannel:
+	default:
+		l.drainDeadline = time.Now().Add(drainTimeout)
+		close(l.stopChannel)
+	}
+	if l.cancel == nil {
+		// Never started.
+		return nil
+	}
+
+	select {
+	case <-l.doneChannel:
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.cancel(ErrShutdown)
+	<-l.doneChannel
+	return ctx.Err()
+}
+
+// exitReason reports why the loop stopped; nil while it is still running.
+func (l *readLoop) exitReason() error {
+	select {
+	case <-l.doneChannel:
+		return l.err
+	default:
+		return nil
+	}
+}
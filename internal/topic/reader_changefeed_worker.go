@@ -2,68 +2,45 @@ package topic
 
 import (
 	"context"
-	"io"
 	"log"
+	"ydb-sample/internal/changefeed"
 
 	"github.com/ydb-platform/ydb-go-sdk/v3/topic"
-	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicoptions"
-	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicreader"
 )
 
-type ReaderChangefeedWorker struct {
-	topicReader *topicreader.Reader
-	quitChannel chan bool
-}
-
-func NewReaderChangefeedWorker(topicClient topic.Client) (*ReaderChangefeedWorker, error) {
-	var reader, err = topicClient.StartReader(
+// NewReaderChangefeedWorker consumes the issues/updates changefeed as the
+// test consumer and logs every decoded change.
+func NewReaderChangefeedWorker(topicClient topic.Client, opts ...ConsumerOption) (*Consumer, error) {
+	return NewConsumer(
+		topicClient,
 		"test",
-		topicoptions.ReadTopic("issues/updates"),
+		[]string{"issues/updates"},
+		HandlerFunc(LogIssueChange),
+		append([]ConsumerOption{WithName("reader changefeed worker")}, opts...)...,
 	)
-	if err != nil {
-		return nil, err
-	}
-
-	return &ReaderChangefeedWorker{
-		topicReader: reader,
-		quitChannel: make(chan bool),
-	}, nil
 }
 
-func (w *ReaderChangefeedWorker) ReadChangefeed(ctx context.Context) {
-	var goroutine = func() {
-		for true {
-			var message, err = w.topicReader.ReadMessage(ctx)
-			if err != nil {
-				log.Printf("Error happened: %v\n", err)
-				continue
-			}
-
-			content, err := io.ReadAll(message)
-			if err != nil {
-				log.Printf("Error happened: %v\n", err)
-				continue
-			}
-
-			log.Printf("Received message: %v\n", string(content))
-
-			err = w.topicReader.Commit(message.Context(), message)
-			if err != nil {
-				log.Printf("Error happened: %v\n", err)
-				continue
-			}
+// LogIssueChange logs one changefeed record; undecodable records are skipped.
+func LogIssueChange(ctx context.Context, message *Message) error {
+	change, err := changefeed.DecodeIssueChange(message.Data)
+	if err != nil {
+		log.Printf("Skipping undecodable record at offset %d: %v\n", message.Offset, err)
+		return nil
+	}
 
-			if message.SeqNo == 4 {
-				log.Println("Stopping reader changefeed worker!")
-				w.quitChannel <- true
-				return
-			}
-		}
+	if change.Kind == changefeed.KindResolved {
+		log.Printf("Changefeed resolved up to %v\n", change.VirtualTimestamp)
+		return nil
 	}
-	go goroutine()
-}
 
-func (w *ReaderChangefeedWorker) Shutdown(ctx context.Context) error {
-	<-w.quitChannel
-	return w.topicReader.Close(ctx)
+	log.Printf(
+		"Received %v of %s at %v: %v -> %v\n",
+		change.Kind,
+		change.Key,
+		change.VirtualTimestamp,
+		change.OldImage,
+		change.NewImage,
+	)
+
+	return nil
 }
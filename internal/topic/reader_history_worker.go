@@ -0,0 +1,53 @@
+package topic
+
+import (
+	"context"
+	"log"
+	"ydb-sample/internal/issue"
+	"ydb-sample/internal/query"
+	"ydb-sample/internal/statusevent"
+
+	ydbQuery "github.com/ydb-platform/ydb-go-sdk/v3/query"
+)
+
+// NewStatusHistoryWorker consumes task_status as the history consumer and
+// appends every status change to issue_status_history. It reads in
+// transactions, so offsets and history rows commit together.
+func NewStatusHistoryWorker(helper *query.QueryHelper, opts ...ConsumerOption) (*Consumer, error) {
+	var history = issue.NewStatusHistoryRepository(helper)
+
+	return NewTransactionalConsumer(
+		helper,
+		"history",
+		[]string{"task_status"},
+		TxBatchHandlerFunc(func(ctx context.Context, tx ydbQuery.TxActor, messages []*Message) error {
+			return history.AppendInTx(ctx, tx, statusHistoryEntries(messages))
+		}),
+		append([]ConsumerOption{WithName("status history worker")}, opts...)...,
+	)
+}
+
+// statusHistoryEntries skips undecodable events: a failed transaction
+// would only read the same batch again, and it has no dead-letter topic.
+func statusHistoryEntries(messages []*Message) []issue.StatusHistoryEntry {
+	var entries = make([]issue.StatusHistoryEntry, 0, len(messages))
+
+	for _, message := range messages {
+		event, err := statusevent.Decode(message.Data, message.Metadata)
+		if err != nil {
+			log.Printf("Skipping undecodable status event at offset %d: %v\n", message.Offset, err)
+			continue
+		}
+
+		entries = append(entries, issue.StatusHistoryEntry{
+			IssueId:   event.IssueId,
+			ChangedAt: event.Timestamp,
+			EventId:   event.EventId,
+			OldStatus: event.OldStatus,
+			NewStatus: event.NewStatus,
+			Actor:     event.Actor,
+		})
+	}
+
+	return entries
+}
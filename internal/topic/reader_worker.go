@@ -2,68 +2,81 @@ package topic
 
 import (
 	"context"
-	"io"
 	"log"
+	"ydb-sample/internal/dedup"
+	"ydb-sample/internal/notification"
+	"ydb-sample/internal/query"
+	"ydb-sample/internal/statusevent"
 
+	ydbQuery "github.com/ydb-platform/ydb-go-sdk/v3/query"
 	"github.com/ydb-platform/ydb-go-sdk/v3/topic"
-	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicoptions"
-	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicreader"
 )
 
-type ReaderWorker struct {
-	topicReader *topicreader.Reader
-	quitChannel chan bool
-}
+const emailConsumer = "email"
+
+// NewReaderWorker consumes task_status as the email consumer and queues a
+// notification for every status change. Events are deduplicated by their
+// event id, so redelivered messages queue nothing twice. Undecodable
+// messages fail the handler, so pass a DeadLetter middleware to keep them
+// from blocking the partition.
+func NewReaderWorker(
+	topicClient topic.Client,
+	helper *query.QueryHelper,
+	opts ...ConsumerOption,
+) (*Consumer, error) {
+	var notifications = notification.NewNotificationRepository(helper)
 
-func NewReaderWorker(topicClient topic.Client) (*ReaderWorker, error) {
-	var reader, err = topicClient.StartReader(
-		"email",
-		topicoptions.ReadTopic("task_status"),
+	return NewConsumer(
+		topicClient,
+		emailConsumer,
+		[]string{"task_status"},
+		Idempotent(
+			helper,
+			dedup.NewProcessedEventRepository(),
+			emailConsumer,
+			statusEventId,
+			TxHandlerFunc(func(ctx context.Context, tx ydbQuery.TxActor, message *Message) error {
+				return queueNotification(ctx, tx, notifications, message)
+			}),
+		),
+		append([]ConsumerOption{WithName("reader worker")}, opts...)...,
 	)
+}
+
+func statusEventId(message *Message) (string, error) {
+	event, err := statusevent.Decode(message.Data, message.Metadata)
 	if err != nil {
-		return nil, err
+		// Retrying cannot fix the payload; a DeadLetter middleware moves
+		// it out of the way once the retry budget is spent.
+		return "", err
 	}
-
-	return &ReaderWorker{
-		topicReader: reader,
-		quitChannel: make(chan bool),
-	}, nil
+	return event.EventId.String(), nil
 }
 
-func (w *ReaderWorker) Run(ctx context.Context) {
-	var goroutine = func() {
-		for true {
-			var message, err = w.topicReader.ReadMessage(ctx)
-			if err != nil {
-				log.Printf("Error happened: %v\n", err)
-				continue
-			}
-
-			content, err := io.ReadAll(message)
-			if err != nil {
-				log.Printf("Error happened: %v\n", err)
-				continue
-			}
-
-			log.Printf("Received message: %v\n", string(content))
-
-			err = w.topicReader.Commit(message.Context(), message)
-			if err != nil {
-				log.Printf("Error happened: %v\n", err)
-				continue
-			}
-
-			if message.SeqNo == 6 {
-				log.Println("Stopping reader worker!")
-				w.quitChannel <- true
-				return
-			}
-		}
+func queueNotification(
+	ctx context.Context,
+	tx ydbQuery.TxActor,
+	notifications *notification.NotificationRepository,
+	message *Message,
+) error {
+	event, err := statusevent.Decode(message.Data, message.Metadata)
+	if err != nil {
+		return err
 	}
-	go goroutine()
-}
 
-func (w *ReaderWorker) Shutdown(ctx context.Context) error {
-	<-w.quitChannel
-	return w.topicReader.Close(ctx)
+	log.Printf(
+		"Received status change: %s %q -> %q by %s\n",
+		event.IssueId,
+		event.OldStatus,
+		event.NewStatus,
+		event.Actor,
+	)
+
+	return notifications.Enqueue(ctx, tx, notification.Notification{
+		EventId:   event.EventId,
+		IssueId:   event.IssueId,
+		OldStatus: event.OldStatus,
+		NewStatus: event.NewStatus,
+		QueuedAt:  event.Timestamp,
+	})
 }
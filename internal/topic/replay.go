@@ -0,0 +1,105 @@
+package topic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicoptions"
+)
+
+const (
+	// replayIdleTimeout ends a replay once no message arrived for this
+	// long; partitions with nothing left in the window send nothing.
+	replayIdleTimeout = 5 * time.Second
+	// replayLimit bounds the whole replay in case partitions neither reach
+	// their end nor go quiet.
+	replayLimit = 10 * time.Minute
+)
+
+// Replay passes every retained message of path written in [from, to) to
+// handler. It reads without a consumer, so no committed offset moves and
+// nothing is committed; to zero means up to the newest message when the
+// replay started. Replay returns how many messages were handled once every
+// partition passed the window or reached the end it had at the start, or
+// the first handler error. Messages written during the replay do not keep
+// it running.
+func Replay(
+	ctx context.Context,
+	topicClient topic.Client,
+	path string,
+	from time.Time,
+	to time.Time,
+	handler Handler,
+) (int, error) {
+	partitions, err := describePartitions(ctx, topicClient, path)
+	if err != nil {
+		return 0, err
+	}
+
+	// pending holds the last offset to replay of every partition that
+	// still has messages.
+	var pending = make(map[int64]int64, len(partitions))
+	for id, partition := range partitions {
+		var offsets = partition.PartitionStats.PartitionsOffset
+		if offsets.End > offsets.Start {
+			pending[id] = offsets.End - 1
+		}
+	}
+
+	reader, err := topicClient.StartReader(
+		"",
+		topicoptions.ReadSelectors{{Path: path, ReadFrom: from}},
+		topicoptions.WithReaderWithoutConsumer(false),
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = reader.Close(ctx) }()
+
+	var limitCtx, cancel = context.WithTimeout(ctx, replayLimit)
+	defer cancel()
+
+	var handled = 0
+	for len(pending) > 0 {
+		raw, err := readWithin(limitCtx, reader, replayIdleTimeout)
+		switch {
+		case ctx.Err() != nil:
+			return handled, ctx.Err()
+		case limitCtx.Err() != nil:
+			return handled, fmt.Errorf("replay of %s did not finish within %v", path, replayLimit)
+		case errors.Is(err, context.DeadlineExceeded):
+			return handled, nil
+		case err != nil:
+			return handled, err
+		}
+
+		var last, ok = pending[raw.PartitionID()]
+		if !ok {
+			// Finished, or created after the replay started.
+			continue
+		}
+		if !to.IsZero() && !raw.WrittenAt.Before(to) {
+			delete(pending, raw.PartitionID())
+			continue
+		}
+		if raw.Offset >= last {
+			delete(pending, raw.PartitionID())
+		}
+
+		message, err := newMessage(raw)
+		if err != nil {
+			return handled, err
+		}
+
+		err = handler.Handle(ctx, message)
+		if err != nil {
+			return handled, err
+		}
+		handled++
+	}
+
+	return handled, nil
+}
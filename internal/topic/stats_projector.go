@@ -0,0 +1,44 @@
+package topic
+
+import (
+	"context"
+	"log"
+	"ydb-sample/internal/changefeed"
+	"ydb-sample/internal/projection"
+	"ydb-sample/internal/query"
+
+	ydbQuery "github.com/ydb-platform/ydb-go-sdk/v3/query"
+)
+
+// ProjectionsChangefeed is the changefeed the read-model projections consume.
+const ProjectionsChangefeed = "issues/projections"
+
+// NewStatsProjector consumes issues/projections as the stats consumer and
+// keeps author_stats and status_stats up to date. Counters are adjusted
+// by deltas, so it reads in transactions: a batch applied twice would
+// count twice.
+func NewStatsProjector(
+	helper *query.QueryHelper,
+	statsRepo *projection.StatsRepository,
+	opts ...ConsumerOption,
+) (*Consumer, error) {
+	return NewTransactionalConsumer(
+		helper,
+		"stats",
+		[]string{ProjectionsChangefeed},
+		TxBatchHandlerFunc(func(ctx context.Context, tx ydbQuery.TxActor, messages []*Message) error {
+			var deltas = projection.NewDeltas()
+			for _, message := range messages {
+				change, err := changefeed.DecodeIssueChange(message.Data)
+				if err != nil {
+					log.Printf("Skipping undecodable record at offset %d: %v\n", message.Offset, err)
+					continue
+				}
+				deltas.Apply(change)
+			}
+
+			return statsRepo.ApplyInTx(ctx, tx, deltas)
+		}),
+		append([]ConsumerOption{WithName("stats projector")}, opts...)...,
+	)
+}
@@ -1,65 +1,122 @@
 package topic
 
 import (
-	"bytes"
 	"context"
-	"fmt"
 	"ydb-sample/internal/issue"
+	"ydb-sample/internal/outbox"
+	"ydb-sample/internal/query"
+	"ydb-sample/internal/statusevent"
 
 	"github.com/google/uuid"
-	"github.com/ydb-platform/ydb-go-sdk/v3/topic"
-	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicoptions"
-	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicwriter"
+	ydbQuery "github.com/ydb-platform/ydb-go-sdk/v3/query"
 )
 
+// StatusUpdateService changes issue statuses and records a task_status
+// event in the outbox within the same transaction. OutboxRelay publishes
+// the events afterwards.
 type StatusUpdateService struct {
-	issueRepo   *issue.IssueRepository
-	topicWriter *topicwriter.Writer
+	helper     *query.QueryHelper
+	issueRepo  *issue.IssueRepository
+	outboxRepo *outbox.OutboxRepository
+	encoding   statusevent.Encoding
 }
 
 func NewStatusUpdateService(
+	helper *query.QueryHelper,
 	issueRepo *issue.IssueRepository,
-	topicClient topic.Client,
-) (*StatusUpdateService, error) {
-	var topicWriter, err = topicClient.StartWriter(
-		"task_status",
-		topicoptions.WithWriterProducerID("producer-task-status"),
-	)
-	if err != nil {
-		return nil, err
-	}
-
+	outboxRepo *outbox.OutboxRepository,
+	encoding statusevent.Encoding,
+) *StatusUpdateService {
 	return &StatusUpdateService{
-		issueRepo: issueRepo,
-		topicWriter: topicWriter,
-	}, nil
+		helper:     helper,
+		issueRepo:  issueRepo,
+		outboxRepo: outboxRepo,
+		encoding:   encoding,
+	}
 }
 
+// Update moves the issue to status and enqueues the task_status event of
+// the change; a no-op update records nothing.
 func (s *StatusUpdateService) Update(
 	ctx context.Context,
 	id uuid.UUID,
-	status string,
+	status issue.Status,
+	actor string,
 ) error {
-	var err = s.issueRepo.UpdateStatus(id, status)
-	if err != nil {
-		return err
-	}
+	return s.helper.ExecuteInTx(
+		ctx,
+		func(ctx context.Context, tx ydbQuery.TxActor) error {
+			change, err := s.issueRepo.UpdateStatusInTx(ctx, tx, id, status, actor)
+			if err != nil {
+				return err
+			}
+			if !change.Changed() {
+				return nil
+			}
+
+			return s.enqueue(ctx, tx, change)
+		},
+	)
+}
 
-	var data = fmt.Sprintf("[%s: %s]", id, status)
+// PromoteFutures moves every FUTURE issue to NEW and enqueues one event per
+// promoted issue in the same transaction.
+func (s *StatusUpdateService) PromoteFutures(
+	ctx context.Context,
+	actor string,
+) ([]issue.StatusChange, error) {
+	var changes []issue.StatusChange
 
-	return s.topicWriter.Write(
+	var err = s.helper.ExecuteInTx(
 		ctx,
-		topicwriter.Message{
-			Data: bytes.NewReader([]byte(data)),
+		func(ctx context.Context, tx ydbQuery.TxActor) error {
+			var err error
+			changes, err = s.issueRepo.PromoteFuturesInTx(ctx, tx, actor)
+			if err != nil {
+				return err
+			}
+
+			for _, change := range changes {
+				err = s.enqueue(ctx, tx, &change)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
 		},
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
 }
 
-func (s *StatusUpdateService) Shutdown(ctx context.Context) error {
-	var err = s.topicWriter.Flush(ctx)
+func (s *StatusUpdateService) enqueue(
+	ctx context.Context,
+	tx ydbQuery.TxActor,
+	change *issue.StatusChange,
+) error {
+	var event = statusevent.StatusChanged{
+		EventId:   uuid.New(),
+		IssueId:   change.Id,
+		OldStatus: string(change.From),
+		NewStatus: string(change.To),
+		Actor:     change.Actor,
+		Timestamp: change.ChangedAt,
+	}
+
+	data, err := statusevent.Encode(event, s.encoding)
 	if err != nil {
 		return err
 	}
 
-	return s.topicWriter.Close(ctx)
+	return s.outboxRepo.Enqueue(ctx, tx, outbox.Message{
+		Id:            event.EventId,
+		CreatedAt:     event.Timestamp,
+		IssueId:       change.Id,
+		Payload:       data,
+		ContentType:   string(s.encoding),
+		SchemaVersion: statusevent.SchemaVersion,
+	})
 }
@@ -0,0 +1,89 @@
+package topic
+
+import (
+	"context"
+	"ydb-sample/internal/query"
+
+	ydbQuery "github.com/ydb-platform/ydb-go-sdk/v3/query"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicreader"
+)
+
+// TxBatchHandler writes the results of a batch inside the transaction that
+// also commits the batch offsets.
+type TxBatchHandler interface {
+	HandleBatchTx(ctx context.Context, tx ydbQuery.TxActor, messages []*Message) error
+}
+
+type TxBatchHandlerFunc func(ctx context.Context, tx ydbQuery.TxActor, messages []*Message) error
+
+func (f TxBatchHandlerFunc) HandleBatchTx(ctx context.Context, tx ydbQuery.TxActor, messages []*Message) error {
+	return f(ctx, tx, messages)
+}
+
+// NewTransactionalConsumer reads batches with PopMessagesBatchTx: the
+// offsets and the handler's writes commit in one YDB transaction, so every
+// batch is applied exactly once and handlers need no deduplication. When
+// the transaction fails the SDK reconnects and the batch is read again.
+//
+// WithBatching limits the batch size; middlewares, commit policies and
+// partition concurrency do not apply.
+func NewTransactionalConsumer(
+	helper *query.QueryHelper,
+	consumer string,
+	topics []string,
+	handler TxBatchHandler,
+	opts ...ConsumerOption,
+) (*Consumer, error) {
+	var c, err = newConsumer(helper.Topic(), consumer, topics, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.loop = newReadLoop(
+		c.name,
+		c.topicReader,
+		func(ctx context.Context) (*delivery, error) {
+			return c.applyInTx(ctx, helper, handler)
+		},
+		nil,
+	)
+
+	return c, nil
+}
+
+// applyInTx pops one batch and runs the handler in the same transaction.
+// The returned delivery is empty: there is nothing left to handle or commit.
+func (c *Consumer) applyInTx(
+	ctx context.Context,
+	helper *query.QueryHelper,
+	handler TxBatchHandler,
+) (*delivery, error) {
+	var err = helper.ExecuteInTx(
+		ctx,
+		func(ctx context.Context, tx ydbQuery.TxActor) error {
+			batch, err := c.topicReader.PopMessagesBatchTx(
+				ctx,
+				tx,
+				topicreader.WithBatchMaxCount(c.batchSize),
+			)
+			if err != nil {
+				return err
+			}
+
+			var next = &delivery{}
+			for _, raw := range batch.Messages {
+				next.addMessage(c.name, raw)
+			}
+			if len(next.messages) == 0 {
+				return nil
+			}
+
+			return handler.HandleBatchTx(ctx, tx, next.messages)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &delivery{}, nil
+}